@@ -0,0 +1,135 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package platform_connector_lib
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/iot"
+	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"log"
+	"time"
+)
+
+type DeviceEvent struct {
+	DeviceId  string
+	ServiceId string
+	Msg       EventMsg
+	Time      time.Time //measurement time; time.Now() is used if zero
+}
+
+//resolves device, device-type and protocol once per distinct device/service and produces all events with one kafka request (if Config.SyncKafka is true).
+//errs contains one entry per event
+func (this *Connector) HandleDeviceEventBatch(token security.JwtToken, events []DeviceEvent) (errs []error) {
+	errs = make([]error, len(events))
+	resolver := newEventMetadataResolver(this.IotCache.WithToken(token))
+	messages := []kafka.Message{}
+	indexes := []int{}
+	for i, event := range events {
+		eventTime, err := this.checkEventTime(event.Time)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		device, service, protocol, err := resolver.get(event.DeviceId, event.ServiceId)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		envelope, err := this.unmarshalMsg(token, device, service, protocol, event.Msg, this.eventCharacteristics...)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		envelope.Time = eventTime
+		jsonMsg, err := json.Marshal(envelope)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		messages = append(messages, kafka.Message{Topic: model.ServiceIdToTopic(event.ServiceId), Key: event.DeviceId, Value: string(jsonMsg), Timestamp: eventTime})
+		indexes = append(indexes, i)
+	}
+	if len(messages) == 0 {
+		return errs
+	}
+	if this.Config.Debug {
+		defer func(start time.Time) {
+			log.Println("DEBUG: kafka produce batch of", len(messages), "in", time.Now().Sub(start))
+		}(time.Now())
+	}
+	for j, err := range this.produceBatch(messages) {
+		if err != nil {
+			this.handleEventProduceError(messages[j], err)
+			errs[indexes[j]] = err
+		}
+	}
+	return errs
+}
+
+//caches device, device-type and protocol lookups (including errors) for the duration of one batch
+type eventMetadataResolver struct {
+	iot      *iot.Cache
+	devices  map[string]deviceLookup
+	services map[string]serviceLookup
+}
+
+type deviceLookup struct {
+	device model.Device
+	dt     model.DeviceType
+	err    error
+}
+
+type serviceLookup struct {
+	service  model.Service
+	protocol model.Protocol
+	err      error
+}
+
+func newEventMetadataResolver(iot *iot.Cache) *eventMetadataResolver {
+	return &eventMetadataResolver{iot: iot, devices: map[string]deviceLookup{}, services: map[string]serviceLookup{}}
+}
+
+func (this *eventMetadataResolver) get(deviceId string, serviceId string) (device model.Device, service model.Service, protocol model.Protocol, err error) {
+	d, ok := this.devices[deviceId]
+	if !ok {
+		d.device, d.err = this.iot.GetDevice(deviceId)
+		if d.err == nil {
+			d.dt, d.err = this.iot.GetDeviceType(d.device.DeviceTypeId)
+		}
+		this.devices[deviceId] = d
+	}
+	if d.err != nil {
+		return device, service, protocol, d.err
+	}
+	serviceKey := d.dt.Id + "." + serviceId
+	s, ok := this.services[serviceKey]
+	if !ok {
+		s.err = errors.New("unknown service id")
+		for _, candidate := range d.dt.Services {
+			if candidate.Id == serviceId {
+				s.service = candidate
+				s.protocol, s.err = this.iot.GetProtocol(candidate.ProtocolId)
+				break
+			}
+		}
+		this.services[serviceKey] = s
+	}
+	return d.device, s.service, s.protocol, s.err
+}
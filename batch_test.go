@@ -0,0 +1,117 @@
+package platform_connector_lib
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/platform-connector-lib/cache"
+	"github.com/SENERGY-Platform/platform-connector-lib/conversion"
+	"github.com/SENERGY-Platform/platform-connector-lib/iot"
+	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/SENERGY-Platform/platform-connector-lib/validation"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newBatchTestConnector(t *testing.T, producer kafka.ProducerInterface) (connector *Connector, requests *int32, close func()) {
+	requests = new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(requests, 1)
+		switch {
+		case request.URL.Path == "/devices/device1":
+			json.NewEncoder(writer).Encode(model.Device{Id: "device1", DeviceTypeId: "dt1"})
+		case request.URL.Path == "/device-types/dt1":
+			json.NewEncoder(writer).Encode(model.DeviceType{Id: "dt1", Services: []model.Service{{
+				Id:         "service1",
+				ProtocolId: "p1",
+				Outputs:    []model.Content{{ContentVariable: model.ContentVariable{Name: "level", Type: model.Integer}, Serialization: "json", ProtocolSegmentId: "s1"}},
+			}}})
+		case request.URL.Path == "/protocols/p1":
+			json.NewEncoder(writer).Encode(model.Protocol{Id: "p1", ProtocolSegments: []model.ProtocolSegment{{Id: "s1", Name: "body"}}})
+		case strings.HasPrefix(request.URL.Path, "/devices/"):
+			http.Error(writer, "not found", http.StatusNotFound)
+		default:
+			t.Error("unexpected request", request.URL.Path)
+			http.Error(writer, "unexpected request", http.StatusInternalServerError)
+		}
+	}))
+	connector = &Connector{
+		IotCache:    iot.NewCacheWithBackend(iot.New(server.URL, server.URL), 0, 0, cache.NewNoopBackend()),
+		producer:    producer,
+		marshallers: marshalling.NewRegistry(),
+		conversions: conversion.New(),
+		validator:   validation.New(validation.Disabled),
+	}
+	return connector, requests, server.Close
+}
+
+func TestHandleDeviceEventBatch(t *testing.T) {
+	producer := &producerMock{responseTopic: model.ServiceIdToTopic("service1"), failures: 1}
+	connector, requests, close := newBatchTestConnector(t, producer)
+	defer close()
+
+	errs := connector.HandleDeviceEventBatch("Bearer token", []DeviceEvent{
+		{DeviceId: "device1", ServiceId: "service1", Msg: EventMsg{"body": `{"level":1}`}},
+		{DeviceId: "unknown", ServiceId: "service1", Msg: EventMsg{"body": `{"level":2}`}},
+		{DeviceId: "device1", ServiceId: "unknown", Msg: EventMsg{"body": `{"level":3}`}},
+		{DeviceId: "device1", ServiceId: "service1", Msg: EventMsg{"body": `invalid`}},
+		{DeviceId: "device1", ServiceId: "service1", Msg: EventMsg{"body": `{"level":5}`}},
+	})
+	if len(errs) != 5 {
+		t.Fatal(errs)
+	}
+	//the first produced message fails
+	if errs[0] == nil || errs[1] == nil || errs[2] == nil || errs[3] == nil || errs[4] != nil {
+		t.Fatal(errs)
+	}
+	if len(producer.messages) != 1 || producer.messages[0].Key != "device1" || !strings.Contains(producer.messages[0].Value, `"level":5`) {
+		t.Fatal(producer.messages)
+	}
+	//device, device-type, protocol and the unknown device are requested once
+	if *requests != 4 {
+		t.Fatal(*requests)
+	}
+}
+
+type reportingProducerMock struct {
+	producerMock
+}
+
+func (this *reportingProducerMock) ProduceWithReport(message kafka.Message, report func(report kafka.DeliveryReport)) (err error) {
+	return this.ProduceBatchWithReport([]kafka.Message{message}, report)[0]
+}
+
+func (this *reportingProducerMock) ProduceBatchWithReport(messages []kafka.Message, report func(report kafka.DeliveryReport)) (errs []error) {
+	errs = this.ProduceBatch(messages)
+	for i, message := range messages {
+		report(kafka.DeliveryReport{Topic: message.Topic, Key: message.Key, Message: message.Value, Err: errs[i], Attempts: 1})
+	}
+	return errs
+}
+
+func (this *reportingProducerMock) Statistics() kafka.ProducerStatistics {
+	return kafka.ProducerStatistics{}
+}
+
+func TestHandleDeviceEventBatchDeliveryReports(t *testing.T) {
+	producer := &reportingProducerMock{}
+	connector, _, close := newBatchTestConnector(t, producer)
+	defer close()
+	reports := []kafka.DeliveryReport{}
+	connector.SetDeliveryReportHandler(func(report kafka.DeliveryReport) {
+		reports = append(reports, report)
+	})
+	errs := connector.HandleDeviceEventBatch("Bearer token", []DeviceEvent{
+		{DeviceId: "device1", ServiceId: "service1", Msg: EventMsg{"body": `{"level":1}`}},
+		{DeviceId: "device1", ServiceId: "service1", Msg: EventMsg{"body": `{"level":2}`}},
+	})
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatal(errs)
+	}
+	if len(reports) != 2 || reports[0].Key != "device1" || reports[1].Err != nil {
+		t.Fatal(reports)
+	}
+}
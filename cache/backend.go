@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/coocood/freecache"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+//second level storage of Cache
+type Backend interface {
+	Get(key string) (value []byte, err error)             //returns ErrNotFound for unknown keys
+	Set(key string, value []byte, expiration int32) error //expiration in seconds; 0 stores without expiration
+	Delete(key string) error                              //unknown keys are no error
+}
+
+type memcachedBackend struct {
+	client *memcache.Client
+}
+
+func NewMemcachedBackend(memcacheUrl ...string) Backend {
+	return &memcachedBackend{client: memcache.New(memcacheUrl...)}
+}
+
+func (this *memcachedBackend) Get(key string) (value []byte, err error) {
+	item, err := this.client.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.Value, nil
+}
+
+func (this *memcachedBackend) Set(key string, value []byte, expiration int32) error {
+	return this.client.Set(&memcache.Item{Value: value, Expiration: expiration, Key: key})
+}
+
+func (this *memcachedBackend) Delete(key string) error {
+	err := this.client.Delete(key)
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+type RedisOptions struct {
+	Addr     string //host:port
+	Password string
+	Db       int
+}
+
+type redisBackend struct {
+	client *redis.Client
+}
+
+func NewRedisBackend(options RedisOptions) Backend {
+	return &redisBackend{client: redis.NewClient(&redis.Options{Addr: options.Addr, Password: options.Password, DB: options.Db})}
+}
+
+func (this *redisBackend) Get(key string) (value []byte, err error) {
+	value, err = this.client.Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (this *redisBackend) Set(key string, value []byte, expiration int32) error {
+	return this.client.Set(key, value, time.Duration(expiration)*time.Second).Err()
+}
+
+func (this *redisBackend) Delete(key string) error {
+	return this.client.Del(key).Err()
+}
+
+type localBackend struct {
+	cache *freecache.Cache
+}
+
+//in-process storage for deployments without shared cache; size in bytes
+func NewLocalBackend(size int) Backend {
+	return &localBackend{cache: freecache.NewCache(size)}
+}
+
+func (this *localBackend) Get(key string) (value []byte, err error) {
+	value, err = this.cache.Get([]byte(key))
+	if err == freecache.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (this *localBackend) Set(key string, value []byte, expiration int32) error {
+	return this.cache.Set([]byte(key), value, int(expiration))
+}
+
+func (this *localBackend) Delete(key string) error {
+	this.cache.Del([]byte(key))
+	return nil
+}
+
+type noopBackend struct{}
+
+//stores nothing; Cache only uses its L1
+func NewNoopBackend() Backend {
+	return noopBackend{}
+}
+
+func (noopBackend) Get(key string) (value []byte, err error) {
+	return nil, ErrNotFound
+}
+
+func (noopBackend) Set(key string, value []byte, expiration int32) error {
+	return nil
+}
+
+func (noopBackend) Delete(key string) error {
+	return nil
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"testing"
+)
+
+func testBackend(t *testing.T, backend Backend) {
+	_, err := backend.Get("foo")
+	if err != ErrNotFound {
+		t.Fatal(err)
+	}
+	err = backend.Set("foo", []byte("bar"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := backend.Get("foo")
+	if err != nil || string(value) != "bar" {
+		t.Fatal(string(value), err)
+	}
+	err = backend.Delete("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = backend.Get("foo")
+	if err != ErrNotFound {
+		t.Fatal(err)
+	}
+	err = backend.Delete("unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisBackend(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	backend := NewRedisBackend(RedisOptions{Addr: server.Addr()})
+	testBackend(t, backend)
+
+	err = backend.Set("expiring", []byte("value"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("expiring"); ttl.Seconds() != 10 {
+		t.Fatal(ttl)
+	}
+}
+
+func TestLocalBackend(t *testing.T) {
+	testBackend(t, NewLocalBackend(1024*1024))
+}
+
+func TestNoopBackend(t *testing.T) {
+	backend := NewNoopBackend()
+	err := backend.Set("foo", []byte("bar"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = backend.Get("foo")
+	if err != ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheWithBackend(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	backend := NewRedisBackend(RedisOptions{Addr: server.Addr()})
+	backend.Set("l2only", []byte("value"), 10)
+
+	cache := NewWithBackend(backend)
+	item, err := cache.Get("l2only")
+	if err != nil || string(item.Value) != "value" {
+		t.Fatal(string(item.Value), err)
+	}
+	cache.Set("both", []byte("value2"), 10)
+	if value, err := backend.Get("both"); err != nil || string(value) != "value2" {
+		t.Fatal(string(value), err)
+	}
+	_, err = cache.Get("unknown")
+	if err != ErrNotFound {
+		t.Fatal(err)
+	}
+}
@@ -2,20 +2,32 @@ package cache
 
 import (
 	"errors"
-	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/coocood/freecache"
+	"golang.org/x/sync/singleflight"
 	"log"
-	"sync"
 )
 
+//defaults for Options.L1Expiration and Options.L1Size; only used by caches created after a change
 var L1Expiration = 2           // 2sec
 var L1Size = 100 * 1024 * 1024 //100MB
+//Deprecated: enables debug logs for all caches; use Options.Debug
 var Debug = false
 
+type Options struct {
+	L1Size             int   //bytes; 0 uses L1Size
+	L1Expiration       int   //seconds; 0 uses L1Expiration
+	DisableL2          bool  //only use the in-process l1 cache; the backend is ignored
+	L1FullExpiration   bool  //l1 entries keep the expiration passed to Set() instead of L1Expiration; combined with DisableL2 the l1 replaces an in-process l2
+	NegativeExpiration int32 //seconds loader errors marked with Negative() are cached; 0 disables negative caching
+	Debug              bool
+}
+
+//safe for concurrent use (freecache and all backends are)
 type Cache struct {
-	l1  *freecache.Cache
-	l2  *memcache.Client
-	mux sync.Mutex
+	l1      *freecache.Cache
+	l2      Backend
+	loader  singleflight.Group
+	options Options
 }
 
 type Item struct {
@@ -25,49 +37,176 @@ type Item struct {
 
 var ErrNotFound = errors.New("key not found in cache")
 
+//returned by Get and GetOrLoad while a negative entry for the key is cached
+var ErrNegative = errors.New("negative cache entry")
+
+//value of negative entries; can not collide with json values
+var negativeMarker = []byte("\x00negative")
+
+//negative entries are stored in l2 under a prefixed key, so that instances without negative caching never read them as values
+const negativeKeyPrefix = "negative."
+
+func negativeKey(key string) string {
+	return negativeKeyPrefix + key
+}
+
+type negativeError struct {
+	err error
+}
+
+func (this negativeError) Error() string {
+	return this.err.Error()
+}
+
+func (this negativeError) Unwrap() error {
+	return this.err
+}
+
+//marks a loader error as cacheable; GetOrLoad returns the unwrapped error and caches a negative entry for Options.NegativeExpiration seconds
+func Negative(err error) error {
+	return negativeError{err: err}
+}
+
 func New(memcacheUrl ...string) *Cache {
-	return &Cache{l1: freecache.NewCache(L1Size), l2: memcache.New(memcacheUrl...)}
+	return NewWithBackend(NewMemcachedBackend(memcacheUrl...))
+}
+
+func NewWithBackend(l2 Backend) *Cache {
+	return NewWithOptions(l2, Options{})
+}
+
+//l2 may be nil if options.DisableL2 is set
+func NewWithOptions(l2 Backend, options Options) *Cache {
+	if options.L1Size == 0 {
+		options.L1Size = L1Size
+	}
+	if options.L1Expiration == 0 {
+		options.L1Expiration = L1Expiration
+	}
+	if options.DisableL2 || l2 == nil {
+		l2 = NewNoopBackend()
+	}
+	return &Cache{l1: freecache.NewCache(options.L1Size), l2: l2, options: options}
+}
+
+func (this *Cache) debug() bool {
+	return this.options.Debug || Debug
 }
 
 func (this *Cache) Get(key string) (item Item, err error) {
-	this.mux.Lock()
-	defer this.mux.Unlock()
 	item.Value, err = this.l1.Get([]byte(key))
 	if err != nil && err != freecache.ErrNotFound {
 		log.Println("ERROR: in Cache::l1.Get()", err)
 	}
 	if err != nil {
-		if Debug {
+		if this.debug() {
 			log.Println("DEBUG: use l2 cache", key, err)
 		}
-		var temp *memcache.Item
-		temp, err = this.l2.Get(key)
-		if err == memcache.ErrCacheMiss {
-			err = ErrNotFound
-			return
+		var value []byte
+		value, err = this.l2.Get(key)
+		if err == ErrNotFound && this.options.NegativeExpiration > 0 {
+			value, err = this.l2.Get(negativeKey(key))
+			if err == nil {
+				value = negativeMarker
+			}
 		}
 		if err != nil {
 			return
 		}
-		err := this.l1.Set([]byte(key), temp.Value, L1Expiration)
+		err := this.l1.Set([]byte(key), value, this.l1Expiration(value, -1))
 		if err != nil {
 			log.Println("ERROR: in Cache::l1.Set()", err)
 		}
-		item.Value = temp.Value
+		item.Value = value
+	}
+	if isNegative(item.Value) {
+		return Item{}, ErrNegative
 	}
 	return
 }
 
 func (this *Cache) Set(key string, value []byte, expiration int32) {
-	this.mux.Lock()
-	defer this.mux.Unlock()
-	err := this.l1.Set([]byte(key), value, L1Expiration)
+	err := this.l1.Set([]byte(key), value, this.l1Expiration(value, expiration))
 	if err != nil {
 		log.Println("ERROR: in Cache::l1.Set()", err)
 	}
-	err = this.l2.Set(&memcache.Item{Value: value, Expiration: expiration, Key: key})
+	if isNegative(value) {
+		key = negativeKey(key)
+	}
+	err = this.l2.Set(key, value, expiration)
 	if err != nil {
 		log.Println("ERROR: in Cache::l2.Set()", err)
 	}
 	return
 }
+
+//removes key from l1 and l2; other instances may still hold the value in their l1 cache for up to Options.L1Expiration seconds
+func (this *Cache) Remove(key string) {
+	this.l1.Del([]byte(key))
+	err := this.l2.Delete(key)
+	if err != nil {
+		log.Println("ERROR: in Cache::l2.Delete()", err)
+	}
+	if this.options.NegativeExpiration > 0 {
+		err = this.l2.Delete(negativeKey(key))
+		if err != nil {
+			log.Println("ERROR: in Cache::l2.Delete()", err)
+		}
+	}
+}
+
+//returns the cached value or calls loader and caches its result.
+//concurrent calls for the same key share one loader call; loader errors are only cached if marked with Negative()
+func (this *Cache) GetOrLoad(key string, loader func() ([]byte, error), expiration int32) (value []byte, err error) {
+	item, err := this.Get(key)
+	if err == nil {
+		return item.Value, nil
+	}
+	if err == ErrNegative {
+		return nil, err
+	}
+	if err != ErrNotFound {
+		log.Println("ERROR: in Cache::GetOrLoad()", err)
+	}
+	result, err, _ := this.loader.Do(key, func() (interface{}, error) {
+		//an other call may have loaded the value between Get and Do
+		if value, err := this.l1.Get([]byte(key)); err == nil {
+			if isNegative(value) {
+				return nil, ErrNegative
+			}
+			return value, nil
+		}
+		value, err := loader()
+		if negative, ok := err.(negativeError); ok {
+			if this.options.NegativeExpiration > 0 {
+				this.Set(key, negativeMarker, this.options.NegativeExpiration)
+			}
+			return nil, negative.err
+		}
+		if err != nil {
+			return nil, err
+		}
+		this.Set(key, value, expiration)
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]byte), nil
+}
+
+//negative entries are not kept longer in l1 than Options.NegativeExpiration.
+//expiration is the expiration passed to Set(); negative if unknown
+func (this *Cache) l1Expiration(value []byte, expiration int32) int {
+	if this.options.L1FullExpiration && expiration >= 0 {
+		return int(expiration)
+	}
+	if isNegative(value) && this.options.NegativeExpiration > 0 && int(this.options.NegativeExpiration) < this.options.L1Expiration {
+		return int(this.options.NegativeExpiration)
+	}
+	return this.options.L1Expiration
+}
+
+func isNegative(value []byte) bool {
+	return string(value) == string(negativeMarker)
+}
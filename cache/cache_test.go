@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadSingleFlight(t *testing.T) {
+	cache := NewWithBackend(NewLocalBackend(1024 * 1024))
+	calls := int32(0)
+	release := make(chan bool)
+	loader := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte("value"), nil
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.GetOrLoad("key", loader, 10)
+			if err != nil || string(value) != "value" {
+				t.Error(string(value), err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatal(calls)
+	}
+	value, err := cache.GetOrLoad("key", loader, 10)
+	if err != nil || string(value) != "value" || calls != 1 {
+		t.Fatal(string(value), err, calls)
+	}
+}
+
+type hookBackend struct {
+	Backend
+	get func(key string)
+}
+
+func (this hookBackend) Get(key string) (value []byte, err error) {
+	this.get(key)
+	return this.Backend.Get(key)
+}
+
+func TestGetOrLoadRecheck(t *testing.T) {
+	blocked := make(chan bool)
+	loaded := make(chan bool)
+	gets := int32(0)
+	//the first caller misses l2 before the second caller loads the value and enters GetOrLoad.Do() after it
+	cache := NewWithBackend(hookBackend{Backend: NewNoopBackend(), get: func(key string) {
+		if atomic.AddInt32(&gets, 1) == 1 {
+			close(blocked)
+			<-loaded
+		}
+	}})
+	calls := int32(0)
+	loader := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		return []byte("value"), nil
+	}
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		value, err := cache.GetOrLoad("key", loader, 10)
+		if err != nil || string(value) != "value" {
+			t.Error(string(value), err)
+		}
+	}()
+	<-blocked
+	value, err := cache.GetOrLoad("key", loader, 10)
+	if err != nil || string(value) != "value" {
+		t.Fatal(string(value), err)
+	}
+	close(loaded)
+	<-done
+	if calls != 1 {
+		t.Fatal(calls)
+	}
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	cache := NewWithBackend(NewNoopBackend())
+	expected := errors.New("test error")
+	_, err := cache.GetOrLoad("key", func() ([]byte, error) {
+		return nil, expected
+	}, 10)
+	if err != expected {
+		t.Fatal(err)
+	}
+	value, err := cache.GetOrLoad("key", func() ([]byte, error) {
+		return []byte("value"), nil
+	}, 10)
+	if err != nil || string(value) != "value" {
+		t.Fatal(string(value), err)
+	}
+}
+
+func TestGetOrLoadNegative(t *testing.T) {
+	backend := NewLocalBackend(1024 * 1024)
+	cache := NewWithOptions(backend, Options{L1Size: 1024 * 1024, L1Expiration: 60, NegativeExpiration: 1})
+	expected := errors.New("not found")
+	calls := 0
+	loader := func() ([]byte, error) {
+		calls++
+		return nil, Negative(expected)
+	}
+	_, err := cache.GetOrLoad("key", loader, 10)
+	if err != expected {
+		t.Fatal(err)
+	}
+	_, err = cache.GetOrLoad("key", loader, 10)
+	if err != ErrNegative || calls != 1 {
+		t.Fatal(err, calls)
+	}
+	//negative entries are not kept longer in l1 than NegativeExpiration
+	if ttl, err := cache.l1.TTL([]byte("key")); err != nil || ttl > 1 {
+		t.Fatal(ttl, err)
+	}
+	//expire the entry
+	cache.l1.Del([]byte("key"))
+	backend.Delete(negativeKey("key"))
+	_, err = cache.GetOrLoad("key", loader, 10)
+	if err != expected || calls != 2 {
+		t.Fatal(err, calls)
+	}
+}
+
+func TestGetOrLoadNegativeDisabled(t *testing.T) {
+	cache := NewWithOptions(NewLocalBackend(1024*1024), Options{L1Size: 1024 * 1024})
+	expected := errors.New("not found")
+	calls := 0
+	loader := func() ([]byte, error) {
+		calls++
+		return nil, Negative(expected)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetOrLoad("key", loader, 10)
+		if err != expected {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Fatal(calls)
+	}
+}
+
+func TestOptionsDisableL2(t *testing.T) {
+	backend := NewLocalBackend(1024 * 1024)
+	cache := NewWithOptions(backend, Options{L1Size: 1024 * 1024, L1Expiration: 1, DisableL2: true})
+	cache.Set("key", []byte("value"), 10)
+	item, err := cache.Get("key")
+	if err != nil || string(item.Value) != "value" {
+		t.Fatal(string(item.Value), err)
+	}
+	if _, err = backend.Get("key"); err != ErrNotFound {
+		t.Fatal(err)
+	}
+	if ttl, err := cache.l1.TTL([]byte("key")); err != nil || ttl > 1 {
+		t.Fatal(ttl, err)
+	}
+}
+
+func TestOptionsL1FullExpiration(t *testing.T) {
+	cache := NewWithOptions(nil, Options{L1Size: 1024 * 1024, L1Expiration: 1, DisableL2: true, L1FullExpiration: true})
+	cache.Set("key", []byte("value"), 60)
+	ttl, err := cache.l1.TTL([]byte("key"))
+	if err != nil || ttl <= 1 {
+		t.Fatal(ttl, err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	backend := NewLocalBackend(1024 * 1024)
+	cache := NewWithOptions(backend, Options{L1Size: 1024 * 1024})
+	cache.Set("key", []byte("value"), 10)
+	cache.Remove("key")
+	if _, err := cache.Get("key"); err != ErrNotFound {
+		t.Fatal(err)
+	}
+	if _, err := backend.Get("key"); err != ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestNegativeSharedL2(t *testing.T) {
+	l2 := NewLocalBackend(1024 * 1024)
+	negative := NewWithOptions(l2, Options{L1Size: 1024 * 1024, NegativeExpiration: 10})
+	expected := errors.New("not found")
+	_, err := negative.GetOrLoad("key", func() ([]byte, error) {
+		return nil, Negative(expected)
+	}, 10)
+	if err != expected {
+		t.Fatal(err)
+	}
+
+	//instances without negative caching (e.g. older versions during a rolling upgrade) must not see the negative entry
+	_, err = l2.Get("key")
+	if err != ErrNotFound {
+		t.Fatal(err)
+	}
+	plain := NewWithOptions(l2, Options{L1Size: 1024 * 1024})
+	_, err = plain.Get("key")
+	if err != ErrNotFound {
+		t.Fatal(err)
+	}
+
+	other := NewWithOptions(l2, Options{L1Size: 1024 * 1024, NegativeExpiration: 10})
+	_, err = other.Get("key")
+	if err != ErrNegative {
+		t.Fatal(err)
+	}
+
+	//values win over negative entries
+	plain.Set("key", []byte("value"), 10)
+	item, err := NewWithOptions(l2, Options{L1Size: 1024 * 1024, NegativeExpiration: 10}).Get("key")
+	if err != nil || string(item.Value) != "value" {
+		t.Fatal(string(item.Value), err)
+	}
+
+	other.Remove("key")
+	_, err = l2.Get(negativeKey("key"))
+	if err != ErrNotFound {
+		t.Fatal(err)
+	}
+}
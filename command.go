@@ -19,9 +19,9 @@ package platform_connector_lib
 import (
 	"encoding/json"
 	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
 	"log"
-	"runtime/debug"
 	"time"
 )
 
@@ -30,22 +30,126 @@ func (this *Connector) handleCommand(msg []byte, t time.Time) (err error) {
 	err = json.Unmarshal(msg, &protocolmsg)
 	if err != nil {
 		log.Println("ERROR: handle command: ", err.Error())
-		return
+		return kafka.NonRetryable(err)
+	}
+	inflightId, err := this.inflight.add(protocolmsg)
+	if err != nil {
+		return err
+	}
+	defer this.inflight.done(inflightId)
+	protocolParts, err := this.commandInput(protocolmsg)
+	if err != nil {
+		log.Println("ERROR: handle command input: ", err.Error())
+		return kafka.NonRetryable(err)
 	}
-	protocolParts := protocolmsg.Request.Input
+	protocolmsg.Request.Input = protocolParts
 	if this.deviceCommandHandler != nil {
 		handlerResponse, err := this.useDeviceCommandHandler(protocolmsg, protocolParts)
 		if err != nil {
 			return err
 		}
-		return this.HandleCommandResponse(protocolmsg, handlerResponse)
+		return this.retryCommandResponse(protocolmsg, handlerResponse)
 	} else if this.asyncCommandHandler != nil {
 		return this.asyncCommandHandler(protocolmsg, protocolParts, t)
 	}
 	return errors.New("missing command handler")
 }
 
+//the device has already been actuated, so only the response is retried; a final failure is not retried by the consumer
+func (this *Connector) retryCommandResponse(protocolmsg model.ProtocolMsg, response CommandResponseMsg) (err error) {
+	policy := this.retryPolicy()
+	for attempt := 1; ; attempt++ {
+		err = this.produceCommandResponse(protocolmsg, response)
+		if err == nil || attempt >= policy.Attempts {
+			break
+		}
+		log.Println("WARNING: unable to handle command response; retry", attempt, err)
+		time.Sleep(policy.Delay(attempt))
+	}
+	if err != nil {
+		this.handleCommandResponseError(protocolmsg, err)
+		return kafka.NonRetryable(err)
+	}
+	return nil
+}
+
+//returns Request.Input with the serialized Request.Values; serialized values replace existing segments
+//values are converted from Metadata.InputCharacteristic to the characteristics of the service inputs
+func (this *Connector) commandInput(protocolmsg model.ProtocolMsg) (result CommandRequestMsg, err error) {
+	if len(protocolmsg.Request.Values) == 0 {
+		return protocolmsg.Request.Input, nil
+	}
+	values := protocolmsg.Request.Values
+	if inputCharacteristic := protocolmsg.Metadata.InputCharacteristic; inputCharacteristic != "" {
+		values = map[string]interface{}{}
+		for name, value := range protocolmsg.Request.Values {
+			values[name] = value
+		}
+		for _, input := range protocolmsg.Metadata.Service.Inputs {
+			value, ok := values[input.ContentVariable.Name]
+			if !ok {
+				continue
+			}
+			values[input.ContentVariable.Name], err = this.conversions.ConvertFromCharacteristics(value, input.ContentVariable, inputCharacteristic)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+	marshalled, err := this.MarshalCommandInput(protocolmsg, values)
+	if err != nil {
+		return result, err
+	}
+	result = CommandRequestMsg{}
+	for segment, value := range protocolmsg.Request.Input {
+		result[segment] = value
+	}
+	for segment, value := range marshalled {
+		result[segment] = value
+	}
+	return result, nil
+}
+
+//returns TaskInfo.Time of the command or kafkaTime if the command has no valid task time
+func commandTaskTime(msg []byte, kafkaTime time.Time) time.Time {
+	//only the task info is decoded, because this is called for every message, including the expired ones
+	protocolmsg := struct {
+		TaskInfo model.TaskInfo `json:"task_info"`
+	}{}
+	err := json.Unmarshal(msg, &protocolmsg)
+	if err != nil {
+		return kafkaTime
+	}
+	result, err := protocolmsg.TaskInfo.ParseTime()
+	if err != nil {
+		return kafkaTime
+	}
+	return result
+}
+
+func (this *Connector) handleExpiredCommand(topic string, msg []byte, t time.Time, age time.Duration) {
+	if this.expiredCommandHandler == nil {
+		return
+	}
+	protocolmsg := model.ProtocolMsg{}
+	err := json.Unmarshal(msg, &protocolmsg)
+	if err != nil {
+		log.Println("ERROR: handle expired command: ", err.Error())
+		return
+	}
+	this.expiredCommandHandler(protocolmsg, age)
+}
+
 func (this *Connector) HandleCommandResponse(commandRequest model.ProtocolMsg, commandResponse CommandResponseMsg) (err error) {
+	err = this.produceCommandResponse(commandRequest, commandResponse)
+	if err != nil {
+		this.handleCommandResponseError(commandRequest, err)
+	}
+	return err
+}
+
+//like HandleCommandResponse() but produce errors are only returned
+func (this *Connector) produceCommandResponse(commandRequest model.ProtocolMsg, commandResponse CommandResponseMsg) (err error) {
 	if commandRequest.TaskInfo.CompletionStrategy == model.Optimistic {
 		return
 	}
@@ -55,13 +159,17 @@ func (this *Connector) HandleCommandResponse(commandRequest model.ProtocolMsg, c
 		log.Println("ERROR in handleCommand() json.Marshal(): ", err)
 		return err
 	}
-	err = this.producer.ProduceWithKey(this.Config.KafkaResponseTopic, string(responseMsg), commandRequest.Metadata.Device.Id)
-	if err != nil && this.Config.FatalKafkaError {
-		debug.PrintStack()
-		log.Fatal("FATAL: ", err)
+	err = this.produce(kafka.Message{Topic: this.Config.KafkaResponseTopic, Key: commandRequest.Metadata.Device.Id, Value: string(responseMsg)})
+	if err != nil {
+		return err
 	}
 	this.trySendingResponseAsEvent(commandRequest, commandResponse)
-	return err
+	return nil
+}
+
+//passes errors of the response topic to handleKafkaError(), which stops the connector if Config.FatalKafkaError is set
+func (this *Connector) handleCommandResponseError(commandRequest model.ProtocolMsg, err error) {
+	this.handleKafkaError(kafka.DeliveryReport{Topic: this.Config.KafkaResponseTopic, Key: commandRequest.Metadata.Device.Id, Err: err})
 }
 
 func (this *Connector) useDeviceCommandHandler(msg model.ProtocolMsg, protocolParts map[string]string) (result map[string]string, err error) {
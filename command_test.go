@@ -0,0 +1,133 @@
+package platform_connector_lib
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fails the first failures produce calls for responseTopic
+type producerMock struct {
+	mux           sync.Mutex
+	responseTopic string
+	failures      int
+	messages      []kafka.Message
+}
+
+func (this *producerMock) Produce(topic string, message string) (err error) {
+	return this.ProduceMessage(kafka.Message{Topic: topic, Value: message})
+}
+
+func (this *producerMock) ProduceWithKey(topic string, message string, key string) (err error) {
+	return this.ProduceMessage(kafka.Message{Topic: topic, Value: message, Key: key})
+}
+
+func (this *producerMock) ProduceMessage(message kafka.Message) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if message.Topic == this.responseTopic && this.failures > 0 {
+		this.failures--
+		return errors.New("test error")
+	}
+	this.messages = append(this.messages, message)
+	return nil
+}
+
+func (this *producerMock) ProduceBatch(messages []kafka.Message) (errs []error) {
+	for _, message := range messages {
+		errs = append(errs, this.ProduceMessage(message))
+	}
+	return errs
+}
+
+func (this *producerMock) Log(logger *log.Logger) {}
+
+func (this *producerMock) Close() {}
+
+func newCommandTestConnector(producer kafka.ProducerInterface, actuations *int) *Connector {
+	connector := &Connector{
+		Config:      Config{KafkaResponseTopic: "response", KafkaRetryAttempts: 3, KafkaRetryBackoff: 0.001},
+		security:    security.New("", "", "", "", "", 0, 0, 0, nil),
+		producer:    producer,
+		inflight:    newInflightCommands(),
+		marshallers: marshalling.NewRegistry(),
+	}
+	connector.SetDeviceCommandHandler(func(deviceId string, deviceUri string, serviceId string, serviceUri string, requestMsg CommandRequestMsg) (CommandResponseMsg, error) {
+		*actuations++
+		return CommandResponseMsg{"body": "ok"}, nil
+	})
+	return connector
+}
+
+func TestCommandResponseRetryWithoutActuation(t *testing.T) {
+	actuations := 0
+	producer := &producerMock{responseTopic: "response", failures: 2}
+	connector := newCommandTestConnector(producer, &actuations)
+	err := connector.handleCommand([]byte(`{"metadata":{"device":{"id":"device1"}}}`), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actuations != 1 || len(producer.messages) != 1 || producer.messages[0].Topic != "response" {
+		t.Fatal(actuations, producer.messages)
+	}
+}
+
+func TestCommandResponseFailureIsNonRetryable(t *testing.T) {
+	actuations := 0
+	producer := &producerMock{responseTopic: "response", failures: 10}
+	connector := newCommandTestConnector(producer, &actuations)
+	err := connector.handleCommand([]byte(`{"metadata":{"device":{"id":"device1"}}}`), time.Now())
+	if !kafka.IsNonRetryable(err) {
+		t.Fatal(err)
+	}
+	if actuations != 1 || producer.failures != 7 {
+		t.Fatal(actuations, producer.failures)
+	}
+}
+
+func TestCommandTaskTime(t *testing.T) {
+	kafkaTime := time.Now()
+	result := commandTaskTime([]byte(`{"task_info":{"time":"1600000000"},"request":{"input":{"body":"x"}}}`), kafkaTime)
+	if !result.Equal(time.Unix(1600000000, 0)) {
+		t.Fatal(result)
+	}
+	if result = commandTaskTime([]byte(`{"task_info":{}}`), kafkaTime); !result.Equal(kafkaTime) {
+		t.Fatal(result)
+	}
+	if result = commandTaskTime([]byte(`invalid`), kafkaTime); !result.Equal(kafkaTime) {
+		t.Fatal(result)
+	}
+}
+
+func TestCommandResponseFatalKafkaError(t *testing.T) {
+	actuations := 0
+	producer := &producerMock{responseTopic: "response", failures: 10}
+	connector := newCommandTestConnector(producer, &actuations)
+	connector.Config.FatalKafkaError = true
+	connector.fatalKafkaErrors = make(chan kafka.DeliveryReport, 1)
+	handled := 0
+	connector.SetKafkaErrorHandler(func(report kafka.DeliveryReport) {
+		handled++
+	})
+	//must not stop the process; the final error is passed to the fatal error handling of the connector
+	err := connector.handleCommand([]byte(`{"metadata":{"device":{"id":"device1"}}}`), time.Now())
+	if !kafka.IsNonRetryable(err) {
+		t.Fatal(err)
+	}
+	if producer.failures != 7 || handled != 1 {
+		t.Fatal(producer.failures, handled)
+	}
+	select {
+	case report := <-connector.fatalKafkaErrors:
+		if report.Topic != "response" || report.Key != "device1" {
+			t.Fatal(report)
+		}
+	default:
+		t.Fatal("missing fatal kafka error")
+	}
+}
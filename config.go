@@ -19,6 +19,7 @@ package platform_connector_lib
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
 	"log"
 	"os"
 	"reflect"
@@ -28,12 +29,39 @@ import (
 )
 
 type Config struct {
-	ZookeeperUrl       string //host1:2181,host2:2181/chroot
+	ZookeeperUrl       string   //host1:2181,host2:2181/chroot
+	KafkaBootstrap     []string //host1:9092,host2:9092; if set, kafka is used without zookeeper
 	KafkaResponseTopic string
 	KafkaGroupName     string
 	FatalKafkaError    bool
 	Protocol           string
 
+	KafkaTlsEnabled            bool
+	KafkaTlsCaFile             string //pem file; system cert pool is used if empty
+	KafkaTlsCertFile           string //pem file with client certificate
+	KafkaTlsKeyFile            string //pem file with client key
+	KafkaTlsInsecureSkipVerify bool
+	KafkaSaslMechanism         string //PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512; sasl is disabled if empty
+	KafkaSaslUser              string
+	KafkaSaslPassword          string
+
+	KafkaTopicPartitions        int64                       //partitions of created topics; values < 1 are handled as 1
+	KafkaTopicReplicationFactor int64                       //replication factor of created topics; values < 1 are handled as 1
+	KafkaTopicConfig            map[string]string           //configs of created topics (e.g. retention.ms:604800000,cleanup.policy:delete)
+	KafkaTopicPolicyOverrides   []kafka.TopicPolicyOverride //replaces the settings above for topics with matching pattern; json only
+
+	KafkaConsumerWorkers        int64   //number of concurrently handled commands; commands for the same device are handled in order
+	KafkaRetryAttempts          int64   //command handler calls per command before the command is moved to KafkaDeadLetterTopic
+	KafkaRetryBackoff           float64 //seconds between the first and second attempt; doubled for every following attempt
+	KafkaRetryMaxBackoff        float64 //seconds
+	KafkaDeadLetterTopic        string  //optional; failed commands are only logged if empty
+	KafkaDeadLetterMaxReplays   int64   //ReplayDeadLetters() skips commands which have already been replayed this often; 0 uses 3
+	KafkaDeadLetterParkingTopic string  //optional; receives the commands skipped by ReplayDeadLetters() and unparseable dead letters
+
+	KafkaMaxMessageAge          float64 //seconds; older commands are not handled. 0 uses 1h; negative values disable the check
+	KafkaMessageAgeFromTaskTime bool    //use TaskInfo.Time of the command instead of the kafka timestamp to compute the command age
+	KafkaExpiredToDeadLetter    bool    //produce expired commands to KafkaDeadLetterTopic
+
 	DeviceManagerUrl string
 	DeviceRepoUrl    string
 
@@ -51,9 +79,34 @@ type Config struct {
 	TokenCacheExpiration int32
 	IotCacheUrl          []string
 	TokenCacheUrl        []string
+	CacheBackend         string //memcached (default; uses IotCacheUrl and TokenCacheUrl), redis, local (in-process; l1 only, entries are kept for their full expiration) or none (only short lived l1 cache)
+	RedisAddr            string //host:port; used if CacheBackend is redis
+	RedisPassword        string
+	RedisDb              int64
 	SyncKafka            bool
 	SyncKafkaIdempotent  bool
+	AsyncKafkaRetries    int64   //number of times a failed message is enqueued again, if SyncKafka is false
+	AsyncKafkaBackoff    float64 //seconds to wait before a failed message is enqueued again
 	Debug                bool
+
+	DeviceCacheL1Size             int64 //bytes; 0 uses 100MB
+	DeviceCacheL1Expiration       int64 //seconds; 0 uses 2s
+	DeviceCacheNegativeExpiration int64 //seconds unknown local device ids are cached; independent of DeviceExpiration. 0 disables negative caching
+	DeviceTypeCacheL1Size         int64 //bytes; devices and device-types share one l1 cache if their l1 settings are equal (DeviceCacheNegativeExpiration is not compared); otherwise each allocates its own
+	DeviceTypeCacheL1Expiration   int64
+	TokenCacheL1Size              int64
+	TokenCacheL1Expiration        int64
+	CacheDebug                    bool
+
+	DeviceCacheInvalidationTopic     string //optional; e.g. devices. cached devices are removed when a command for them is published on this topic; the topic must exist
+	DeviceTypeCacheInvalidationTopic string //optional; e.g. device-types
+
+	ShutdownTimeout int64 //seconds to wait for running command handlers and pending kafka messages if the context given to StartWithContext() is done; 0 uses 10s
+
+	EventTimeMaxFuture float64 //seconds; events with a time further in the future are rejected. 0 disables the check
+	EventTimeMaxAge    float64 //seconds; events with an older time are rejected. 0 disables the check
+
+	EventValidation string //reject, clamp or flag events which do not match their service outputs or characteristic bounds; disabled if empty; unknown values are handled as reject
 }
 
 //loads config from json in location and used environment variables (e.g ZookeeperUrl --> ZOOKEEPER_URL)
@@ -88,6 +141,10 @@ func fieldNameToEnvName(s string) string {
 	return strings.ToUpper(strings.Join(a, "_"))
 }
 
+func isSecretField(fieldName string) bool {
+	return strings.Contains(fieldName, "Password") || strings.Contains(fieldName, "Secret") || strings.Contains(fieldName, "PrivateKey")
+}
+
 // preparations for docker
 func handleEnvironmentVars(config *Config) {
 	configValue := reflect.Indirect(reflect.ValueOf(config))
@@ -97,7 +154,11 @@ func handleEnvironmentVars(config *Config) {
 		envName := fieldNameToEnvName(fieldName)
 		envValue := os.Getenv(envName)
 		if envValue != "" {
-			fmt.Println("use environment variable: ", envName, " = ", envValue)
+			if isSecretField(fieldName) {
+				fmt.Println("use environment variable: ", envName, " = ***")
+			} else {
+				fmt.Println("use environment variable: ", envName, " = ", envValue)
+			}
 			if configValue.FieldByName(fieldName).Kind() == reflect.Int64 {
 				i, _ := strconv.ParseInt(envValue, 10, 64)
 				configValue.FieldByName(fieldName).SetInt(i)
@@ -113,7 +174,7 @@ func handleEnvironmentVars(config *Config) {
 				f, _ := strconv.ParseFloat(envValue, 64)
 				configValue.FieldByName(fieldName).SetFloat(f)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Slice && configValue.FieldByName(fieldName).Type().Elem().Kind() == reflect.String {
 				val := []string{}
 				for _, element := range strings.Split(envValue, ",") {
 					val = append(val, strings.TrimSpace(element))
@@ -23,7 +23,11 @@ import (
 )
 
 func New(zk string, sync bool, idempotent bool, deviceLogTopic string, hubLogTopic string) (logger Logger, err error) {
-	producer, err := kafka.PrepareProducer(zk, sync, idempotent)
+	return NewWithCluster(kafka.Cluster{ZookeeperUrl: zk}, sync, idempotent, deviceLogTopic, hubLogTopic)
+}
+
+func NewWithCluster(cluster kafka.Cluster, sync bool, idempotent bool, deviceLogTopic string, hubLogTopic string) (logger Logger, err error) {
+	producer, err := kafka.PrepareProducerWithCluster(cluster, sync, idempotent)
 	if err != nil {
 		return logger, err
 	}
@@ -17,11 +17,16 @@
 package platform_connector_lib
 
 import (
+	"context"
 	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/cache"
+	"github.com/SENERGY-Platform/platform-connector-lib/conversion"
 	"github.com/SENERGY-Platform/platform-connector-lib/iot"
 	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling"
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"github.com/SENERGY-Platform/platform-connector-lib/validation"
 	"log"
 	"time"
 )
@@ -34,12 +39,13 @@ type EventMsg = map[ProtocolSegmentName]string
 type EndpointCommandHandler func(endpoint string, requestMsg CommandRequestMsg) (responseMsg CommandResponseMsg, err error)
 type DeviceCommandHandler func(deviceId string, deviceUri string, serviceId string, serviceUri string, requestMsg CommandRequestMsg) (responseMsg CommandResponseMsg, err error)
 type AsyncCommandHandler func(commandRequest model.ProtocolMsg, requestMsg CommandRequestMsg, t time.Time) (err error)
+type ExpiredCommandHandler func(commandRequest model.ProtocolMsg, age time.Duration)
 
 type Connector struct {
 	Config Config
 	//asyncCommandHandler, endpointCommandHandler and deviceCommandHandler are mutual exclusive
-	deviceCommandHandler DeviceCommandHandler //must be able to handle concurrent calls
-	asyncCommandHandler  AsyncCommandHandler  //must be able to handle concurrent calls
+	deviceCommandHandler DeviceCommandHandler //must be able to handle concurrent calls (see Config.KafkaConsumerWorkers)
+	asyncCommandHandler  AsyncCommandHandler  //must be able to handle concurrent calls (see Config.KafkaConsumerWorkers)
 	producer             kafka.ProducerInterface
 	consumer             *kafka.Consumer
 	iot                  *iot.Iot
@@ -47,14 +53,33 @@ type Connector struct {
 
 	IotCache *iot.PreparedCache
 
+	marshallers *marshalling.Registry
+
+	conversions          *conversion.Table
+	eventCharacteristics []string
+
+	validator *validation.Validator
+
 	kafkalogger *log.Logger
+
+	inflight *inflightCommands
+
+	expiredCommandHandler       ExpiredCommandHandler
+	topicPolicyViolationHandler func(violation kafka.TopicPolicyViolation)
+	deliveryReportHandler       func(report kafka.DeliveryReport)
+	kafkaErrorHandler           func(report kafka.DeliveryReport)
+
+	fatalKafkaErrors chan kafka.DeliveryReport //first producer error with Config.FatalKafkaError (see handleKafkaError())
 }
 
+//stops the process; replaced in tests
+var fatal = log.Fatal
+
 func New(config Config) (connector *Connector) {
 	connector = &Connector{
 		Config: config,
 		iot:    iot.New(config.DeviceManagerUrl, config.DeviceRepoUrl),
-		security: security.New(
+		security: security.NewWithCacheOptions(
 			config.AuthEndpoint,
 			config.AuthClientId,
 			config.AuthClientSecret,
@@ -63,13 +88,98 @@ func New(config Config) (connector *Connector) {
 			config.JwtExpiration,
 			config.AuthExpirationTimeBuffer,
 			config.TokenCacheExpiration,
-			config.TokenCacheUrl,
+			newCacheBackend(config, config.TokenCacheUrl),
+			newCacheOptions(config, config.TokenCacheL1Size, config.TokenCacheL1Expiration, 0),
 		),
+		inflight:         newInflightCommands(),
+		fatalKafkaErrors: make(chan kafka.DeliveryReport, 1),
+		marshallers:      marshalling.NewRegistry(),
+		conversions:      conversion.New(),
+		validator:        validation.New(validation.Mode(config.EventValidation)),
 	}
-	connector.IotCache = iot.NewCache(connector.iot, config.DeviceExpiration, config.DeviceTypeExpiration, config.IotCacheUrl...)
+	iotCacheBackend := newCacheBackend(config, config.IotCacheUrl)
+	if iotCacheBackend == nil {
+		iotCacheBackend = cache.NewMemcachedBackend()
+	}
+	connector.IotCache = iot.NewCacheWithOptions(
+		connector.iot,
+		config.DeviceExpiration,
+		config.DeviceTypeExpiration,
+		iotCacheBackend,
+		newCacheOptions(config, config.DeviceCacheL1Size, config.DeviceCacheL1Expiration, config.DeviceCacheNegativeExpiration),
+		newCacheOptions(config, config.DeviceTypeCacheL1Size, config.DeviceTypeCacheL1Expiration, 0),
+	)
+	connector.validator.SetLoader(connector.loadCharacteristic)
 	return
 }
 
+//loads the characteristic bounds of the event validation from the device repository
+func (this *Connector) loadCharacteristic(id string) (characteristic model.Characteristic, err error) {
+	token, err := this.Security().Access()
+	if err != nil {
+		return characteristic, err
+	}
+	return this.iot.GetCharacteristic(id, token)
+}
+
+func newCacheOptions(config Config, l1Size int64, l1Expiration int64, negativeExpiration int64) cache.Options {
+	return cache.Options{
+		L1Size:             int(l1Size),
+		L1Expiration:       int(l1Expiration),
+		DisableL2:          config.CacheBackend == "none" || config.CacheBackend == "local",
+		L1FullExpiration:   config.CacheBackend == "local",
+		NegativeExpiration: int32(negativeExpiration),
+		Debug:              config.CacheDebug,
+	}
+}
+
+//returns the cache backend selected by Config.CacheBackend; nil for memcached without urls
+func newCacheBackend(config Config, memcachedUrls []string) cache.Backend {
+	switch config.CacheBackend {
+	case "redis":
+		return cache.NewRedisBackend(cache.RedisOptions{Addr: config.RedisAddr, Password: config.RedisPassword, Db: int(config.RedisDb)})
+	case "local", "none":
+		//l1 only (see newCacheOptions())
+		return cache.NewNoopBackend()
+	case "memcached", "":
+	default:
+		log.Println("WARNING: unknown cache backend", config.CacheBackend, "; use memcached")
+	}
+	if len(memcachedUrls) == 0 {
+		return nil
+	}
+	return cache.NewMemcachedBackend(memcachedUrls...)
+}
+
+//formats used by this connector; formats registered here override or extend the globally registered formats
+func (this *Connector) Marshallers() *marshalling.Registry {
+	return this.marshallers
+}
+
+//units and converters of characteristics; used to convert event values and command inputs (see SetEventCharacteristics() and Metadata.InputCharacteristic).
+//the table starts empty; values are only converted between characteristics which are registered (see conversion.New())
+func (this *Connector) Conversions() *conversion.Table {
+	return this.conversions
+}
+
+//event values (and command responses sent as events) are converted to the first characteristic their ContentVariable.CharacteristicId can be converted to.
+//must be called before Start()
+func (this *Connector) SetEventCharacteristics(characteristicIds ...string) *Connector {
+	this.eventCharacteristics = characteristicIds
+	return this
+}
+
+//validates event values if Config.EventValidation is set; characteristic bounds are loaded from the device repository
+//if they are not registered with SetCharacteristics()
+func (this *Connector) EventValidator() *validation.Validator {
+	return this.validator
+}
+
+//number of events with validation violations by device and service
+func (this *Connector) EventViolationCounts() []validation.Count {
+	return this.validator.Counts()
+}
+
 func (this *Connector) SetKafkaLogger(logger *log.Logger) {
 	this.kafkalogger = logger
 }
@@ -92,19 +202,107 @@ func (this *Connector) SetAsyncCommandHandler(handler AsyncCommandHandler) *Conn
 	return this
 }
 
+//handler is called for commands which are older than Config.KafkaMaxMessageAge and will not be handled
+func (this *Connector) SetExpiredCommandHandler(handler ExpiredCommandHandler) *Connector {
+	this.expiredCommandHandler = handler
+	return this
+}
+
+//handler is called asynchronously for existing topics with fewer partitions or replicas or other configs than defined in Config; violations are logged if no handler is set
+func (this *Connector) SetTopicPolicyViolationHandler(handler func(violation kafka.TopicPolicyViolation)) *Connector {
+	this.topicPolicyViolationHandler = handler
+	return this
+}
+
+//handler is called for every command response and event, when kafka has persisted the message or the message finally failed.
+//with Config.SyncKafka == false the handler is called asynchronously
+func (this *Connector) SetDeliveryReportHandler(handler func(report kafka.DeliveryReport)) *Connector {
+	this.deliveryReportHandler = handler
+	return this
+}
+
+//handler is called for every message which could not be produced; kafka errors are only logged if no handler is set.
+//if Config.FatalKafkaError is true, the connector is shut down and the process is stopped after the handler returned
+func (this *Connector) SetKafkaErrorHandler(handler func(report kafka.DeliveryReport)) *Connector {
+	this.kafkaErrorHandler = handler
+	return this
+}
+
 func (this *Connector) Start() (err error) {
+	return this.start(context.Background())
+}
+
+const DefaultShutdownTimeout = 10 * time.Second
+
+//starts the connector; if ctx is done, the connector is shut down like by Shutdown() with a deadline of Config.ShutdownTimeout seconds
+func (this *Connector) StartWithContext(ctx context.Context) (err error) {
+	err = this.start(ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		timeout, cancel := context.WithTimeout(context.Background(), this.shutdownTimeout())
+		defer cancel()
+		pending, err := this.Shutdown(timeout)
+		if err != nil {
+			log.Println("ERROR: connector shutdown with", len(pending), "pending commands:", err)
+		}
+	}()
+	return nil
+}
+
+func (this *Connector) shutdownTimeout() time.Duration {
+	if this.Config.ShutdownTimeout <= 0 {
+		return DefaultShutdownTimeout
+	}
+	return time.Duration(this.Config.ShutdownTimeout) * time.Second
+}
+
+func (this *Connector) start(ctx context.Context) (err error) {
 	if this.deviceCommandHandler == nil && this.asyncCommandHandler == nil {
 		return errors.New("missing command handler; use SetAsyncCommandHandler() or SetDeviceCommandHandler()")
 	}
-	this.producer, err = kafka.PrepareProducer(this.Config.ZookeeperUrl, this.Config.SyncKafka, this.Config.SyncKafkaIdempotent)
+	this.producer, err = kafka.PrepareProducerWithOptions(this.KafkaCluster(), this.Config.SyncKafka, this.Config.SyncKafkaIdempotent, kafka.ProducerOptions{
+		Retries:      int(this.Config.AsyncKafkaRetries),
+		RetryBackoff: time.Duration(this.Config.AsyncKafkaBackoff * float64(time.Second)),
+		ErrorHandler: this.handleKafkaError,
+	})
 	if err != nil {
 		log.Println("ERROR: ", err)
 		return err
 	}
+	//the invalidation readers run until ctx is done; they and the producer are stopped if the connector can not be started
+	invalidationCtx, stopInvalidation := context.WithCancel(ctx)
+	defer func() {
+		if err != nil {
+			stopInvalidation()
+			this.producer.Close()
+		}
+	}()
 	if this.kafkalogger != nil {
 		this.producer.Log(this.kafkalogger)
 	}
-	this.consumer, err = kafka.NewConsumer(this.Config.ZookeeperUrl, this.Config.KafkaGroupName, this.Config.Protocol, func(topic string, msg []byte, t time.Time) error {
+	consumerOptions := kafka.ConsumerOptions{
+		Workers:             int(this.Config.KafkaConsumerWorkers),
+		Retry:               this.retryPolicy(),
+		DeadLetterTopic:     this.Config.KafkaDeadLetterTopic,
+		DeadLetterProducer:  this.producer,
+		MaxMessageAge:       time.Duration(this.Config.KafkaMaxMessageAge * float64(time.Second)),
+		ExpiredHandler:      this.handleExpiredCommand,
+		ExpiredToDeadLetter: this.Config.KafkaExpiredToDeadLetter,
+	}
+	if this.Config.KafkaMessageAgeFromTaskTime {
+		consumerOptions.MessageTime = commandTaskTime
+	}
+	if this.Config.DeviceCacheInvalidationTopic != "" || this.Config.DeviceTypeCacheInvalidationTopic != "" {
+		err = this.IotCache.StartInvalidation(invalidationCtx, this.KafkaCluster(), this.Config.DeviceCacheInvalidationTopic, this.Config.DeviceTypeCacheInvalidationTopic)
+		if err != nil {
+			log.Println("ERROR: unable to start cache invalidation", err)
+			return err
+		}
+	}
+	this.consumer, err = kafka.NewConsumerWithOptions(ctx, this.KafkaCluster(), this.Config.KafkaGroupName, this.Config.Protocol, consumerOptions, func(topic string, msg []byte, t time.Time) error {
 		if string(msg) == "topic_init" {
 			return nil
 		}
@@ -117,13 +315,94 @@ func (this *Connector) Start() (err error) {
 			consumer.Restart()
 		}
 	})
-	return
+	if err != nil {
+		return err
+	}
+	//fatal errors which occur before are buffered in fatalKafkaErrors
+	if this.Config.FatalKafkaError {
+		go this.handleFatalKafkaErrors()
+	}
+	return nil
 }
 
+func (this *Connector) retryPolicy() kafka.RetryPolicy {
+	return kafka.RetryPolicy{
+		Attempts:   int(this.Config.KafkaRetryAttempts),
+		Backoff:    time.Duration(this.Config.KafkaRetryBackoff * float64(time.Second)),
+		MaxBackoff: time.Duration(this.Config.KafkaRetryMaxBackoff * float64(time.Second)),
+	}
+}
+
+//produces the commands of Config.KafkaDeadLetterTopic back to the protocol topic; the returned consumer runs until ctx is done or Stop() is called.
+//commands which have already been replayed Config.KafkaDeadLetterMaxReplays times are moved to Config.KafkaDeadLetterParkingTopic or dropped.
+//the connector must be started
+func (this *Connector) ReplayDeadLetters(ctx context.Context) (consumer *kafka.Consumer, err error) {
+	if this.producer == nil {
+		return nil, errors.New("missing producer; use Start()")
+	}
+	if this.Config.KafkaDeadLetterTopic == "" {
+		return nil, errors.New("missing dead letter topic in config")
+	}
+	options := kafka.ReplayOptions{
+		TargetTopic:  this.Config.Protocol,
+		MaxReplays:   int(this.Config.KafkaDeadLetterMaxReplays),
+		ParkingTopic: this.Config.KafkaDeadLetterParkingTopic,
+	}
+	return kafka.ReplayDeadLettersWithOptions(ctx, this.KafkaCluster(), this.Config.KafkaGroupName+"_dead_letter_replay", this.Config.KafkaDeadLetterTopic, options, this.producer, func(err error, consumer *kafka.Consumer) {
+		log.Println("ERROR: dead letter replay", err)
+		consumer.Restart()
+	})
+}
+
+//stops fetching new commands without waiting for running command handlers; use Shutdown() for a graceful stop
 func (this *Connector) Stop() {
 	this.consumer.Stop()
 }
 
+//stops fetching new commands, waits for running command handlers and flushes and closes the kafka producer until ctx is done.
+//returns the commands which were still handled when ctx was done, together with ctx.Err()
+func (this *Connector) Shutdown(ctx context.Context) (pending []model.ProtocolMsg, err error) {
+	if this.consumer != nil {
+		this.consumer.Stop()
+		select {
+		case <-this.consumer.Done():
+		case <-ctx.Done():
+		}
+	}
+	err = this.inflight.wait(ctx)
+	if err != nil {
+		pending = this.inflight.pending()
+		log.Println("WARNING: shutdown while", len(pending), "commands are still handled")
+	}
+	if this.producer != nil {
+		closeErr := closeProducer(ctx, this.producer)
+		if closeErr != nil {
+			log.Println("WARNING: kafka producer closed before all messages were delivered:", closeErr)
+		}
+	}
+	return pending, err
+}
+
+//stops waiting for the producer when ctx is done
+func closeProducer(ctx context.Context, producer kafka.ProducerInterface) error {
+	if p, ok := producer.(interface {
+		CloseWithContext(ctx context.Context) error
+	}); ok {
+		return p.CloseWithContext(ctx)
+	}
+	finished := make(chan struct{})
+	go func() {
+		producer.Close()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (this *Connector) HandleDeviceEvent(username string, password string, deviceId string, serviceId string, protocolParts map[string]string) (err error) {
 	token, err := this.security.GetUserToken(username, password)
 	if err != nil {
@@ -134,7 +413,22 @@ func (this *Connector) HandleDeviceEvent(username string, password string, devic
 }
 
 func (this *Connector) HandleDeviceEventWithAuthToken(token security.JwtToken, deviceId string, serviceId string, eventMsg EventMsg) (err error) {
-	return this.handleDeviceEvent(token, deviceId, serviceId, eventMsg)
+	return this.handleDeviceEvent(token, deviceId, serviceId, eventMsg, time.Time{})
+}
+
+//eventTime is the measurement time of the event (e.g. for buffered readings); time.Now() is used if zero
+func (this *Connector) HandleDeviceEventWithTime(username string, password string, deviceId string, serviceId string, eventMsg EventMsg, eventTime time.Time) (err error) {
+	token, err := this.security.GetUserToken(username, password)
+	if err != nil {
+		log.Println("ERROR HandleDeviceEventWithTime::GetUserToken()", err)
+		return err
+	}
+	return this.HandleDeviceEventWithAuthTokenAndTime(token, deviceId, serviceId, eventMsg, eventTime)
+}
+
+//eventTime is the measurement time of the event (e.g. for buffered readings); time.Now() is used if zero
+func (this *Connector) HandleDeviceEventWithAuthTokenAndTime(token security.JwtToken, deviceId string, serviceId string, eventMsg EventMsg, eventTime time.Time) (err error) {
+	return this.handleDeviceEvent(token, deviceId, serviceId, eventMsg, eventTime)
 }
 
 func (this *Connector) HandleDeviceRefEvent(username string, password string, deviceUri string, serviceUri string, eventMsg EventMsg) (err error) {
@@ -147,7 +441,122 @@ func (this *Connector) HandleDeviceRefEvent(username string, password string, de
 }
 
 func (this *Connector) HandleDeviceRefEventWithAuthToken(token security.JwtToken, deviceUri string, serviceUri string, eventMsg EventMsg) (err error) {
-	return this.handleDeviceRefEvent(token, deviceUri, serviceUri, eventMsg)
+	return this.handleDeviceRefEvent(token, deviceUri, serviceUri, eventMsg, time.Time{})
+}
+
+//eventTime is the measurement time of the event (e.g. for buffered readings); time.Now() is used if zero
+func (this *Connector) HandleDeviceRefEventWithTime(username string, password string, deviceUri string, serviceUri string, eventMsg EventMsg, eventTime time.Time) (err error) {
+	token, err := this.security.GetUserToken(username, password)
+	if err != nil {
+		log.Println("ERROR HandleDeviceRefEventWithTime::GetUserToken()", err)
+		return err
+	}
+	return this.HandleDeviceRefEventWithAuthTokenAndTime(token, deviceUri, serviceUri, eventMsg, eventTime)
+}
+
+//eventTime is the measurement time of the event (e.g. for buffered readings); time.Now() is used if zero
+func (this *Connector) HandleDeviceRefEventWithAuthTokenAndTime(token security.JwtToken, deviceUri string, serviceUri string, eventMsg EventMsg, eventTime time.Time) (err error) {
+	return this.handleDeviceRefEvent(token, deviceUri, serviceUri, eventMsg, eventTime)
+}
+
+//called by the producer (possibly in its delivery goroutine); fatal errors are passed to handleFatalKafkaErrors() and must not stop the process here
+func (this *Connector) handleKafkaError(report kafka.DeliveryReport) {
+	if this.kafkaErrorHandler != nil {
+		this.kafkaErrorHandler(report)
+	} else {
+		log.Println("ERROR: while producing for topic: '", report.Topic, "' :", report.Err)
+	}
+	if this.Config.FatalKafkaError {
+		select {
+		case this.fatalKafkaErrors <- report:
+		default: //a fatal error is already reported
+		}
+	}
+}
+
+//shuts the connector down and stops the process after the first producer error if Config.FatalKafkaError is set
+func (this *Connector) handleFatalKafkaErrors() {
+	report := <-this.fatalKafkaErrors
+	log.Println("FATAL ERROR: while producing for topic: '", report.Topic, "' :", report.Err)
+	timeout, cancel := context.WithTimeout(context.Background(), this.shutdownTimeout())
+	defer cancel()
+	_, err := this.Shutdown(timeout)
+	if err != nil {
+		log.Println("ERROR: connector shutdown after fatal kafka error:", err)
+	}
+	fatal("FATAL: while producing for topic: '", report.Topic, "' :", report.Err)
+}
+
+//uses the delivery report handler if one is set
+//message.Timestamp is ignored if the producer does not implement kafka.MessageProducer
+func (this *Connector) produce(message kafka.Message) error {
+	if this.deliveryReportHandler != nil {
+		if producer, ok := this.producer.(kafka.ReportingProducer); ok {
+			return producer.ProduceWithReport(message, this.deliveryReportHandler)
+		}
+	}
+	if producer, ok := this.producer.(kafka.MessageProducer); ok {
+		return producer.ProduceMessage(message)
+	}
+	return this.producer.ProduceWithKey(message.Topic, message.Value, message.Key)
+}
+
+//batch variant of produce(); errs contains one entry per message
+func (this *Connector) produceBatch(messages []kafka.Message) (errs []error) {
+	if this.deliveryReportHandler != nil {
+		if producer, ok := this.producer.(kafka.ReportingProducer); ok {
+			return producer.ProduceBatchWithReport(messages, this.deliveryReportHandler)
+		}
+	}
+	if producer, ok := this.producer.(kafka.MessageProducer); ok {
+		return producer.ProduceBatch(messages)
+	}
+	for _, message := range messages {
+		errs = append(errs, this.producer.ProduceWithKey(message.Topic, message.Value, message.Key))
+	}
+	return errs
+}
+
+//returns the kafka producer statistics or false if the producer does not count its messages
+func (this *Connector) KafkaProducerStatistics() (statistics kafka.ProducerStatistics, ok bool) {
+	producer, ok := this.producer.(kafka.ReportingProducer)
+	if !ok {
+		return statistics, false
+	}
+	return producer.Statistics(), true
+}
+
+func (this *Connector) IotCacheStatistics() iot.CacheStatistics {
+	return this.IotCache.Statistics()
+}
+
+//returns the kafka cluster described by Config.KafkaBootstrap or Config.ZookeeperUrl with the tls, sasl and topic settings of Config
+func (this *Connector) KafkaCluster() kafka.Cluster {
+	return kafka.Cluster{
+		ZookeeperUrl: this.Config.ZookeeperUrl,
+		Brokers:      this.Config.KafkaBootstrap,
+		Tls: kafka.TlsConfig{
+			Enabled:            this.Config.KafkaTlsEnabled,
+			CaFile:             this.Config.KafkaTlsCaFile,
+			CertFile:           this.Config.KafkaTlsCertFile,
+			KeyFile:            this.Config.KafkaTlsKeyFile,
+			InsecureSkipVerify: this.Config.KafkaTlsInsecureSkipVerify,
+		},
+		Sasl: kafka.SaslConfig{
+			Mechanism: this.Config.KafkaSaslMechanism,
+			User:      this.Config.KafkaSaslUser,
+			Password:  this.Config.KafkaSaslPassword,
+		},
+		Topics: kafka.TopicPolicies{
+			Default: kafka.TopicPolicy{
+				Partitions:        int(this.Config.KafkaTopicPartitions),
+				ReplicationFactor: int(this.Config.KafkaTopicReplicationFactor),
+				Config:            this.Config.KafkaTopicConfig,
+			},
+			Overrides:        this.Config.KafkaTopicPolicyOverrides,
+			ViolationHandler: this.topicPolicyViolationHandler,
+		},
+	}
 }
 
 func (this *Connector) Security() *security.Security {
@@ -0,0 +1,46 @@
+package platform_connector_lib
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFatalKafkaError(t *testing.T) {
+	stopped := make(chan string, 1)
+	fatal = func(v ...interface{}) {
+		stopped <- fmt.Sprint(v...)
+	}
+	defer func() {
+		fatal = log.Fatal
+	}()
+	connector := &Connector{Config: Config{FatalKafkaError: true}, inflight: newInflightCommands(), fatalKafkaErrors: make(chan kafka.DeliveryReport, 1)}
+	handled := 0
+	connector.SetKafkaErrorHandler(func(report kafka.DeliveryReport) {
+		handled++
+	})
+	go connector.handleFatalKafkaErrors()
+
+	//called like in the delivery goroutine of the producer; must neither block nor stop the process
+	connector.handleKafkaError(kafka.DeliveryReport{Topic: "response", Err: errors.New("first")})
+	connector.handleKafkaError(kafka.DeliveryReport{Topic: "response", Err: errors.New("second")})
+	if handled != 2 {
+		t.Fatal(handled)
+	}
+	select {
+	case msg := <-stopped:
+		if !strings.Contains(msg, "first") {
+			t.Fatal(msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("process not stopped")
+	}
+	if _, err := connector.inflight.add(model.ProtocolMsg{}); err != ErrShuttingDown {
+		t.Fatal("connector not shut down", err)
+	}
+}
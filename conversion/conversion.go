@@ -0,0 +1,144 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conversion
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"math"
+	"sync"
+)
+
+//converts a value of one characteristic to another characteristic of the same concept
+type Converter func(in interface{}) (out interface{}, err error)
+
+var ErrUnknownConversion = errors.New("unknown characteristic conversion")
+
+//knows units and concepts of characteristics and explicit converters between characteristics; safe for concurrent use
+type Table struct {
+	mux             sync.RWMutex
+	characteristics map[string]characteristic
+	concepts        map[string]string
+	converters      map[string]map[string]Converter
+}
+
+type characteristic struct {
+	unit    Unit
+	valType model.Type
+}
+
+//returns an empty table: no characteristic is known, so nothing is converted until the characteristics of the platform are registered.
+//the units (e.g. Celsius, Fahrenheit and Kelvin) are predefined, but have to be assigned to characteristic ids with SetUnit() and SetConcept()
+func New() *Table {
+	return &Table{characteristics: map[string]characteristic{}, concepts: map[string]string{}, converters: map[string]map[string]Converter{}}
+}
+
+//values of characteristics with units of the same dimension are converted linearly, if the characteristics belong to the same concept (see SetConcept())
+func (this *Table) SetUnit(characteristicId string, unit Unit) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.characteristics[characteristicId] = characteristic{unit: unit}
+}
+
+//like SetUnit(); converted values are rounded if characteristic.Type is model.Integer
+func (this *Table) SetCharacteristic(c model.Characteristic, unit Unit) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.characteristics[c.Id] = characteristic{unit: unit, valType: c.Type}
+}
+
+//unit conversions are limited to characteristics of the same concept;
+//e.g. percent of a humidity concept is not converted to the 0-255 range of a brightness concept
+func (this *Table) SetConcept(conceptId string, characteristicIds ...string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, id := range characteristicIds {
+		this.concepts[id] = conceptId
+	}
+}
+
+//uses the MinValue and MaxValue of the characteristic as unit (see RangeUnit()); e.g. for percent or 0-255 brightness
+func (this *Table) SetRangeCharacteristic(c model.Characteristic) error {
+	if c.MinValue == c.MaxValue {
+		return errors.New("characteristic " + c.Id + " has no value range")
+	}
+	this.SetCharacteristic(c, RangeUnit(c.MinValue, c.MaxValue))
+	return nil
+}
+
+//explicit converters are used before unit conversions
+func (this *Table) SetConverter(from string, to string, converter Converter) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.converters[from] == nil {
+		this.converters[from] = map[string]Converter{}
+	}
+	this.converters[from][to] = converter
+}
+
+func (this *Table) CanConvert(from string, to string) bool {
+	if from == to {
+		return true
+	}
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	if _, ok := this.converters[from][to]; ok {
+		return true
+	}
+	return this.unitConvertible(from, to)
+}
+
+//characteristics of the same concept with units of the same dimension; requires this.mux
+func (this *Table) unitConvertible(from string, to string) bool {
+	fromCharacteristic, fromOk := this.characteristics[from]
+	toCharacteristic, toOk := this.characteristics[to]
+	fromConcept, fromConceptOk := this.concepts[from]
+	toConcept, toConceptOk := this.concepts[to]
+	return fromOk && toOk && fromConceptOk && toConceptOk &&
+		fromConcept == toConcept &&
+		fromCharacteristic.unit.Dimension == toCharacteristic.unit.Dimension
+}
+
+//returns ErrUnknownConversion if neither a converter nor units of the same dimension and concept are known
+func (this *Table) Convert(from string, to string, value interface{}) (result interface{}, err error) {
+	if from == to {
+		return value, nil
+	}
+	this.mux.RLock()
+	converter, hasConverter := this.converters[from][to]
+	convertible := this.unitConvertible(from, to)
+	fromCharacteristic := this.characteristics[from]
+	toCharacteristic := this.characteristics[to]
+	this.mux.RUnlock()
+	if hasConverter {
+		return converter(value)
+	}
+	if !convertible {
+		return nil, ErrUnknownConversion
+	}
+	f, ok := base.ToFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert %#v from %v to %v: value is not a number", value, from, to)
+	}
+	f = toCharacteristic.unit.fromBase(fromCharacteristic.unit.toBase(f))
+	if toCharacteristic.valType == model.Integer {
+		return int64(math.Round(f)), nil
+	}
+	return f, nil
+}
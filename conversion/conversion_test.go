@@ -0,0 +1,148 @@
+package conversion
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestUnitConversion(t *testing.T) {
+	table := New()
+	table.SetUnit("celsius", Celsius)
+	table.SetUnit("fahrenheit", Fahrenheit)
+	table.SetUnit("watt", Watt)
+	if err := table.SetRangeCharacteristic(model.Characteristic{Id: "percent", MinValue: 0, MaxValue: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.SetRangeCharacteristic(model.Characteristic{Id: "brightness", Type: model.Integer, MinValue: 0, MaxValue: 255}); err != nil {
+		t.Fatal(err)
+	}
+	table.SetConcept("temperature", "celsius", "fahrenheit")
+	table.SetConcept("power", "watt")
+	table.SetConcept("brightness", "percent", "brightness")
+
+	result, err := table.Convert("fahrenheit", "celsius", 212)
+	if err != nil || math.Abs(result.(float64)-100) > 1e-9 {
+		t.Fatal(result, err)
+	}
+	result, err = table.Convert("percent", "brightness", 50.0)
+	if err != nil || result != int64(128) {
+		t.Fatal(result, err)
+	}
+	_, err = table.Convert("celsius", "watt", 1)
+	if err != ErrUnknownConversion {
+		t.Fatal(err)
+	}
+	_, err = table.Convert("celsius", "fahrenheit", "hot")
+	if err == nil {
+		t.Fatal("expected error for non numeric value")
+	}
+}
+
+func TestConverter(t *testing.T) {
+	table := New()
+	table.SetConverter("on-off", "bool", func(in interface{}) (out interface{}, err error) {
+		return in == "on", nil
+	})
+	if !table.CanConvert("on-off", "bool") || table.CanConvert("bool", "on-off") {
+		t.Fatal("unexpected CanConvert result")
+	}
+	result, err := table.Convert("on-off", "bool", "on")
+	if err != nil || result != true {
+		t.Fatal(result, err)
+	}
+}
+
+func TestConvertVariable(t *testing.T) {
+	table := New()
+	table.SetUnit("celsius", Celsius)
+	table.SetUnit("kelvin", Kelvin)
+	table.SetUnit("percent", Percent)
+	table.SetConcept("temperature", "celsius", "kelvin")
+	table.SetConcept("humidity", "percent")
+	variable := model.ContentVariable{
+		Name: "reading",
+		Type: model.Structure,
+		SubContentVariables: []model.ContentVariable{
+			{Name: "temperature", Type: model.Float, CharacteristicId: "kelvin"},
+			{Name: "history", Type: model.List, SubContentVariables: []model.ContentVariable{{Name: "*", Type: model.Float, CharacteristicId: "kelvin"}}},
+			{Name: "humidity", Type: model.Float, CharacteristicId: "percent"},
+		},
+	}
+	value := map[string]interface{}{"temperature": 300.0, "history": []interface{}{273.15}, "humidity": 40.0, "unit": "K"}
+	result, err := table.ConvertToCharacteristics(value, variable, "celsius")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"temperature": 300 + Kelvin.Offset, "history": []interface{}{0.0}, "humidity": 40.0, "unit": "K"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatal(result)
+	}
+	back, err := table.ConvertFromCharacteristics(result, variable, "celsius")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(back.(map[string]interface{})["temperature"].(float64)-300) > 1e-9 {
+		t.Fatal(back)
+	}
+}
+
+func TestConceptMismatch(t *testing.T) {
+	table := New()
+	table.SetUnit("humidity_percent", Percent)
+	table.SetUnit("brightness_percent", Percent)
+	table.SetUnit("brightness_byte", ByteRange)
+	if table.CanConvert("humidity_percent", "brightness_byte") {
+		t.Fatal("units without concept must not be converted")
+	}
+	table.SetConcept("humidity", "humidity_percent")
+	table.SetConcept("brightness", "brightness_percent", "brightness_byte")
+	if table.CanConvert("humidity_percent", "brightness_byte") {
+		t.Fatal("units of different concepts must not be converted")
+	}
+	if _, err := table.Convert("humidity_percent", "brightness_byte", 50); err != ErrUnknownConversion {
+		t.Fatal(err)
+	}
+	result, err := table.Convert("brightness_percent", "brightness_byte", 100)
+	if err != nil || result != 255.0 {
+		t.Fatal(result, err)
+	}
+}
+
+func TestConvertListPaths(t *testing.T) {
+	table := New()
+	table.SetUnit("celsius", Celsius)
+	table.SetUnit("kelvin", Kelvin)
+	table.SetUnit("percent", Percent)
+	table.SetConcept("temperature", "celsius", "kelvin")
+	variable := model.ContentVariable{
+		Name: "reading",
+		Type: model.List,
+		SubContentVariables: []model.ContentVariable{
+			{Name: "0", Type: model.Float, CharacteristicId: "percent"},
+			{Name: "*", Type: model.Float, CharacteristicId: "kelvin"},
+		},
+	}
+	result, characteristics, err := table.ConvertToCharacteristicsWithPaths([]interface{}{40.0, 273.15, 283.15}, variable, "celsius")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []interface{}{40.0, 0.0, 10.0 + 273.15 + Kelvin.Offset}) {
+		t.Fatal(result)
+	}
+	expected := map[string]string{"reading[0]": "percent", "reading[1]": "celsius", "reading[2]": "celsius"}
+	if !reflect.DeepEqual(characteristics, expected) {
+		t.Fatal(characteristics)
+	}
+}
+
+func TestNewTableIsEmpty(t *testing.T) {
+	table := New()
+	if table.CanConvert("celsius", "fahrenheit") {
+		t.Fatal("unregistered characteristics should not be convertible")
+	}
+	if _, err := table.Convert("celsius", "fahrenheit", 21.0); err != ErrUnknownConversion {
+		t.Fatal(err)
+	}
+}
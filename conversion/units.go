@@ -0,0 +1,78 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conversion
+
+//linear unit of a dimension: base value = value * Scale + Offset
+type Unit struct {
+	Dimension string
+	Scale     float64
+	Offset    float64
+}
+
+func (this Unit) toBase(value float64) float64 {
+	return value*this.Scale + this.Offset
+}
+
+func (this Unit) fromBase(value float64) float64 {
+	return (value - this.Offset) / this.Scale
+}
+
+const (
+	Temperature = "temperature"
+	Ratio       = "ratio"
+	Power       = "power"
+	Energy      = "energy"
+	Length      = "length"
+	Duration    = "duration"
+	Pressure    = "pressure"
+)
+
+var (
+	Celsius    = Unit{Dimension: Temperature, Scale: 1}
+	Kelvin     = Unit{Dimension: Temperature, Scale: 1, Offset: -273.15}
+	Fahrenheit = Unit{Dimension: Temperature, Scale: 5.0 / 9.0, Offset: -32 * 5.0 / 9.0}
+
+	UnitInterval = RangeUnit(0, 1)
+	Percent      = RangeUnit(0, 100)
+	ByteRange    = RangeUnit(0, 255)
+
+	Watt     = Unit{Dimension: Power, Scale: 1}
+	Kilowatt = Unit{Dimension: Power, Scale: 1000}
+
+	WattHour     = Unit{Dimension: Energy, Scale: 1}
+	KilowattHour = Unit{Dimension: Energy, Scale: 1000}
+	Joule        = Unit{Dimension: Energy, Scale: 1.0 / 3600}
+
+	Millimeter = Unit{Dimension: Length, Scale: 0.001}
+	Centimeter = Unit{Dimension: Length, Scale: 0.01}
+	Meter      = Unit{Dimension: Length, Scale: 1}
+	Kilometer  = Unit{Dimension: Length, Scale: 1000}
+
+	Millisecond = Unit{Dimension: Duration, Scale: 0.001}
+	Second      = Unit{Dimension: Duration, Scale: 1}
+	Minute      = Unit{Dimension: Duration, Scale: 60}
+	Hour        = Unit{Dimension: Duration, Scale: 3600}
+
+	Pascal      = Unit{Dimension: Pressure, Scale: 1}
+	Hectopascal = Unit{Dimension: Pressure, Scale: 100}
+	Bar         = Unit{Dimension: Pressure, Scale: 100000}
+)
+
+//maps min..max to 0..1 of the Ratio dimension (e.g. percent to 0-255 brightness)
+func RangeUnit(min float64, max float64) Unit {
+	return Unit{Dimension: Ratio, Scale: 1 / (max - min), Offset: -min / (max - min)}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conversion
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"strconv"
+)
+
+//converts all parts of value whose ContentVariable.CharacteristicId can be converted to one of the target characteristics
+//(e.g. device value -> requested characteristic)
+func (this *Table) ConvertToCharacteristics(value interface{}, variable model.ContentVariable, targets ...string) (result interface{}, err error) {
+	result, _, err = this.ConvertToCharacteristicsWithPaths(value, variable, targets...)
+	return result, err
+}
+
+//like ConvertToCharacteristics(); characteristics contains the characteristic of each part of the result with a characteristic
+//by its path (e.g. reading.temperature or reading.history[0]). this is the target characteristic for converted parts.
+func (this *Table) ConvertToCharacteristicsWithPaths(value interface{}, variable model.ContentVariable, targets ...string) (result interface{}, characteristics map[string]string, err error) {
+	characteristics = map[string]string{}
+	result, err = this.walk(variable.Name, value, variable, func(path string, value interface{}, characteristicId string) (interface{}, bool, error) {
+		for _, target := range targets {
+			if this.CanConvert(characteristicId, target) {
+				result, err := this.Convert(characteristicId, target, value)
+				characteristics[path] = target
+				return result, true, err
+			}
+		}
+		characteristics[path] = characteristicId
+		return value, false, nil
+	})
+	return result, characteristics, err
+}
+
+//converts all parts of value, which are in one of the source characteristics, to the ContentVariable.CharacteristicId of their variable
+//(e.g. requested characteristic -> device value)
+func (this *Table) ConvertFromCharacteristics(value interface{}, variable model.ContentVariable, sources ...string) (result interface{}, err error) {
+	return this.walk(variable.Name, value, variable, func(path string, value interface{}, characteristicId string) (interface{}, bool, error) {
+		for _, source := range sources {
+			if this.CanConvert(source, characteristicId) {
+				result, err := this.Convert(source, characteristicId, value)
+				return result, true, err
+			}
+		}
+		return value, false, nil
+	})
+}
+
+//sub variables are matched like in base.Coerce(): by name, list elements by index, otherwise by the base.Wildcard sub variable
+func (this *Table) walk(path string, value interface{}, variable model.ContentVariable, convert func(path string, value interface{}, characteristicId string) (interface{}, bool, error)) (result interface{}, err error) {
+	if value == nil {
+		return nil, nil
+	}
+	if variable.CharacteristicId != "" {
+		result, converted, err := convert(path, value, variable.CharacteristicId)
+		if err != nil || converted {
+			return result, err
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(variable.SubContentVariables) == 0 {
+			return value, nil
+		}
+		result := map[string]interface{}{}
+		for key, element := range v {
+			sub, ok := subVariable(variable, key)
+			if !ok {
+				result[key] = element
+				continue
+			}
+			result[key], err = this.walk(path+"."+key, element, sub, convert)
+			if err != nil {
+				return result, err
+			}
+		}
+		return result, nil
+	case []interface{}:
+		if len(variable.SubContentVariables) == 0 {
+			return value, nil
+		}
+		result := []interface{}{}
+		for i, element := range v {
+			sub, ok := subVariable(variable, strconv.Itoa(i))
+			if !ok {
+				result = append(result, element)
+				continue
+			}
+			converted, err := this.walk(path+"["+strconv.Itoa(i)+"]", element, sub, convert)
+			if err != nil {
+				return result, err
+			}
+			result = append(result, converted)
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+func subVariable(variable model.ContentVariable, name string) (result model.ContentVariable, ok bool) {
+	for _, sub := range variable.SubContentVariables {
+		if sub.Name == name {
+			return sub, true
+		}
+	}
+	for _, sub := range variable.SubContentVariables {
+		if sub.Name == base.Wildcard {
+			return sub, true
+		}
+	}
+	return result, false
+}
@@ -18,18 +18,24 @@ package correlation
 
 import (
 	"encoding/json"
+	"github.com/SENERGY-Platform/platform-connector-lib/cache"
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/satori/go.uuid"
 )
 
 type CorrelationService struct {
-	memcached  *memcache.Client
+	backend    cache.Backend
 	expiration int32
 }
 
 func New(expiration int32, memcachedServer ...string) *CorrelationService {
-	return &CorrelationService{expiration: expiration, memcached: memcache.New(memcachedServer...)}
+	return NewWithBackend(expiration, cache.NewMemcachedBackend(memcachedServer...))
+}
+
+//backend must be shared between all instances which handle the correlated messages (e.g. no cache.NewLocalBackend() if more than one instance is used)
+func NewWithBackend(expiration int32, backend cache.Backend) *CorrelationService {
+	return &CorrelationService{expiration: expiration, backend: backend}
 }
 
 func (this *CorrelationService) Save(msg model.ProtocolMsg) (correlationId string, err error) {
@@ -38,14 +44,18 @@ func (this *CorrelationService) Save(msg model.ProtocolMsg) (correlationId strin
 	if err != nil {
 		return correlationId, err
 	}
-	return correlationId, this.memcached.Set(&memcache.Item{Key: "cid." + correlationId, Value: value, Expiration: this.expiration})
+	return correlationId, this.backend.Set("cid."+correlationId, value, this.expiration)
 }
 
+//returns memcache.ErrCacheMiss for unknown or expired correlation ids, independent of the backend
 func (this *CorrelationService) Get(correlationId string) (msg model.ProtocolMsg, err error) {
-	item, err := this.memcached.Get("cid." + correlationId)
+	value, err := this.backend.Get("cid." + correlationId)
+	if err == cache.ErrNotFound {
+		return msg, memcache.ErrCacheMiss
+	}
 	if err != nil {
 		return msg, err
 	}
-	err = json.Unmarshal(item.Value, &msg)
+	err = json.Unmarshal(value, &msg)
 	return
 }
@@ -19,7 +19,7 @@ package platform_connector_lib
 import (
 	"encoding/json"
 	"errors"
-	"github.com/SENERGY-Platform/platform-connector-lib/marshalling"
+	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"log"
@@ -27,35 +27,56 @@ import (
 	"time"
 )
 
-func (this *Connector) unmarshalMsgFromRef(token security.JwtToken, deviceid string, serviceid string, msg map[string]string) (result map[string]interface{}, err error) {
-	result = map[string]interface{}{}
+var ErrInvalidEventTime = errors.New("event time exceeds Config.EventTimeMaxFuture or Config.EventTimeMaxAge")
+
+//returns time.Now() for zero eventTime and ErrInvalidEventTime if eventTime is outside of the configured limits
+func (this *Connector) checkEventTime(eventTime time.Time) (time.Time, error) {
+	now := time.Now()
+	if eventTime.IsZero() {
+		return now, nil
+	}
+	if this.Config.EventTimeMaxFuture > 0 && eventTime.Sub(now) > time.Duration(this.Config.EventTimeMaxFuture*float64(time.Second)) {
+		return eventTime, ErrInvalidEventTime
+	}
+	if this.Config.EventTimeMaxAge > 0 && now.Sub(eventTime) > time.Duration(this.Config.EventTimeMaxAge*float64(time.Second)) {
+		return eventTime, ErrInvalidEventTime
+	}
+	return eventTime, nil
+}
+
+//returns an envelope with DeviceId, ServiceId, Value, Annotations and Characteristics (see unmarshalMsg())
+func (this *Connector) unmarshalMsgFromRef(token security.JwtToken, deviceid string, serviceid string, msg map[string]string) (envelope model.Envelope, err error) {
 	iot := this.IotCache.WithToken(token)
 	device, err := iot.GetDevice(deviceid)
 	if err != nil {
-		return result, err
+		return envelope, err
 	}
 	dt, err := iot.GetDeviceType(device.DeviceTypeId)
 	if err != nil {
-		return result, err
+		return envelope, err
 	}
 	for _, service := range dt.Services {
 		if service.Id == serviceid {
 			protocol, err := iot.GetProtocol(service.ProtocolId)
 			if err != nil {
-				return result, err
+				return envelope, err
 			}
-			return this.unmarshalMsg(token, device, service, protocol, msg)
+			return this.unmarshalMsg(token, device, service, protocol, msg, this.eventCharacteristics...)
 		}
 	}
-	return result, errors.New("unknown service id")
+	return envelope, errors.New("unknown service id")
 }
 
-func (this *Connector) unmarshalMsg(token security.JwtToken, device model.Device, service model.Service, protocol model.Protocol, msg map[string]string) (result map[string]interface{}, err error) {
-	result = map[string]interface{}{}
+//values are validated (see Config.EventValidation) and converted to the first of the target characteristics they can be converted to.
+//the returned envelope contains the validation violations as Annotations and the resulting characteristic of each converted or
+//characteristic bound part of Value as Characteristics; Time is not set
+func (this *Connector) unmarshalMsg(token security.JwtToken, device model.Device, service model.Service, protocol model.Protocol, msg map[string]string, targetCharacteristics ...string) (envelope model.Envelope, err error) {
+	result := map[string]interface{}{}
+	envelope = model.Envelope{DeviceId: device.Id, ServiceId: service.Id, Value: result}
 	for _, output := range service.Outputs {
-		marshaller, ok := marshalling.Get(output.Serialization)
+		marshaller, ok := this.marshallers.Get(output.Serialization)
 		if !ok {
-			return result, errors.New("unknown format " + output.Serialization)
+			return envelope, errors.New("unknown format " + output.Serialization)
 		}
 		for _, segment := range protocol.ProtocolSegments {
 			if segment.Id == output.ProtocolSegmentId {
@@ -63,17 +84,40 @@ func (this *Connector) unmarshalMsg(token security.JwtToken, device model.Device
 				if ok {
 					out, err := marshaller.Unmarshal(segmentMsg, output.ContentVariable)
 					if err != nil {
-						return result, err
+						return envelope, err
+					}
+					out, violations, err := this.validator.Validate(device.Id, service.Id, out, output.ContentVariable)
+					if err != nil {
+						log.Println("WARNING: reject invalid event value", device.Id, service.Id, err)
+						return envelope, err
+					}
+					for _, violation := range violations {
+						envelope.Annotations = append(envelope.Annotations, violation.Error())
+					}
+					out, characteristics, err := this.conversions.ConvertToCharacteristicsWithPaths(out, output.ContentVariable, targetCharacteristics...)
+					if err != nil {
+						return envelope, err
+					}
+					for path, characteristic := range characteristics {
+						if envelope.Characteristics == nil {
+							envelope.Characteristics = map[string]string{}
+						}
+						envelope.Characteristics[path] = characteristic
 					}
 					result[output.ContentVariable.Name] = out
 				}
 			}
 		}
 	}
-	return result, nil
+	return envelope, nil
 }
 
-func (this *Connector) handleDeviceRefEvent(token security.JwtToken, deviceUri string, serviceUri string, msg EventMsg) error {
+func (this *Connector) handleDeviceRefEvent(token security.JwtToken, deviceUri string, serviceUri string, msg EventMsg, eventTime time.Time) error {
+	eventTime, err := this.checkEventTime(eventTime)
+	if err != nil {
+		log.Println("ERROR: handleDeviceRefEvent::checkEventTime", deviceUri, eventTime, err)
+		return err
+	}
 	device, err := this.IotCache.WithToken(token).GetDeviceByLocalId(deviceUri)
 	if err != nil {
 		log.Println("ERROR: handleDeviceRefEvent::DeviceUrlToIotDevice", err)
@@ -86,9 +130,9 @@ func (this *Connector) handleDeviceRefEvent(token security.JwtToken, deviceUri s
 	}
 	for _, service := range dt.Services {
 		if service.LocalId == serviceUri && len(service.Outputs) > 0 {
-			err = this.handleDeviceEvent(token, device.Id, service.Id, msg)
+			err = this.sendDeviceEvent(token, device.Id, service.Id, msg, eventTime)
 			if err != nil {
-				log.Println("ERROR: handleDeviceRefEvent::handleDeviceEvent", err)
+				log.Println("ERROR: handleDeviceRefEvent::sendDeviceEvent", err)
 				return err
 			}
 		}
@@ -96,13 +140,22 @@ func (this *Connector) handleDeviceRefEvent(token security.JwtToken, deviceUri s
 	return nil
 }
 
-func (this *Connector) handleDeviceEvent(token security.JwtToken, deviceId string, serviceId string, msg EventMsg) (err error) {
-	eventValue, err := this.unmarshalMsgFromRef(token, deviceId, serviceId, msg)
+func (this *Connector) handleDeviceEvent(token security.JwtToken, deviceId string, serviceId string, msg EventMsg, eventTime time.Time) (err error) {
+	eventTime, err = this.checkEventTime(eventTime)
+	if err != nil {
+		log.Println("ERROR: handleDeviceEvent::checkEventTime", deviceId, eventTime, err)
+		return err
+	}
+	return this.sendDeviceEvent(token, deviceId, serviceId, msg, eventTime)
+}
+
+//eventTime has to be checked by checkEventTime()
+func (this *Connector) sendDeviceEvent(token security.JwtToken, deviceId string, serviceId string, msg EventMsg, eventTime time.Time) (err error) {
+	envelope, err := this.unmarshalMsgFromRef(token, deviceId, serviceId, msg)
 	if err != nil {
 		return err
 	}
-	envelope := model.Envelope{DeviceId: deviceId, ServiceId: serviceId}
-	envelope.Value = eventValue
+	envelope.Time = eventTime
 	return this.sendEventEnvelope(envelope)
 }
 
@@ -113,15 +166,17 @@ func (this *Connector) trySendingResponseAsEvent(cmd model.ProtocolMsg, resp Com
 		debug.PrintStack()
 		return
 	}
-	eventValue, err := this.unmarshalMsg(token, cmd.Metadata.Device, cmd.Metadata.Service, cmd.Metadata.Protocol, resp)
+	targetCharacteristics := this.eventCharacteristics
+	if cmd.Metadata.OutputCharacteristic != "" {
+		targetCharacteristics = append([]string{cmd.Metadata.OutputCharacteristic}, targetCharacteristics...)
+	}
+	envelope, err := this.unmarshalMsg(token, cmd.Metadata.Device, cmd.Metadata.Service, cmd.Metadata.Protocol, resp, targetCharacteristics...)
 	if err != nil {
 		log.Println("ERROR: trySendingResponseAsEvent()", err)
 		debug.PrintStack()
 		return
 	}
-
-	envelope := model.Envelope{DeviceId: cmd.Metadata.Device.Id, ServiceId: cmd.Metadata.Service.Id}
-	envelope.Value = eventValue
+	envelope.Time = time.Now()
 
 	err = this.sendEventEnvelope(envelope)
 	if err != nil {
@@ -132,6 +187,9 @@ func (this *Connector) trySendingResponseAsEvent(cmd model.ProtocolMsg, resp Com
 }
 
 func (this *Connector) sendEventEnvelope(envelope model.Envelope) error {
+	if envelope.Time.IsZero() {
+		envelope.Time = time.Now()
+	}
 	jsonMsg, err := json.Marshal(envelope)
 	if err != nil {
 		log.Println("ERROR: handleDeviceEvent::marshaling ", err)
@@ -144,14 +202,16 @@ func (this *Connector) sendEventEnvelope(envelope model.Envelope) error {
 		}(now)
 	}
 	serviceTopic := model.ServiceIdToTopic(envelope.ServiceId)
-	err = this.producer.ProduceWithKey(serviceTopic, string(jsonMsg), envelope.DeviceId)
+	message := kafka.Message{Topic: serviceTopic, Key: envelope.DeviceId, Value: string(jsonMsg), Timestamp: envelope.Time}
+	err = this.produce(message)
 	if err != nil {
-		if this.Config.FatalKafkaError {
-			debug.PrintStack()
-			log.Fatal("FATAL: while producing for topic: '", serviceTopic, "' :", err)
-		}
-		log.Println("ERROR: produce event on service topic ", err)
+		this.handleEventProduceError(message, err)
 		return err
 	}
 	return nil
 }
+
+//passes the error to handleKafkaError(), which stops the connector if Config.FatalKafkaError is set
+func (this *Connector) handleEventProduceError(message kafka.Message, err error) {
+	this.handleKafkaError(kafka.DeliveryReport{Topic: message.Topic, Key: message.Key, Message: message.Value, Timestamp: message.Timestamp, Err: err})
+}
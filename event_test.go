@@ -0,0 +1,56 @@
+package platform_connector_lib
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckEventTime(t *testing.T) {
+	connector := &Connector{Config: Config{EventTimeMaxFuture: 60, EventTimeMaxAge: 3600}}
+	now := time.Now()
+
+	result, err := connector.checkEventTime(time.Time{})
+	if err != nil || result.Before(now) || result.Sub(now) > time.Second {
+		t.Fatal(result, err)
+	}
+	for _, valid := range []time.Time{now, now.Add(30 * time.Second), now.Add(-30 * time.Minute)} {
+		result, err = connector.checkEventTime(valid)
+		if err != nil || !result.Equal(valid) {
+			t.Fatal(valid, result, err)
+		}
+	}
+	for _, invalid := range []time.Time{now.Add(2 * time.Minute), now.Add(-2 * time.Hour)} {
+		_, err = connector.checkEventTime(invalid)
+		if err != ErrInvalidEventTime {
+			t.Fatal(invalid, err)
+		}
+	}
+
+	connector.Config = Config{}
+	for _, valid := range []time.Time{now.Add(24 * time.Hour), now.Add(-24 * 365 * time.Hour)} {
+		result, err = connector.checkEventTime(valid)
+		if err != nil || !result.Equal(valid) {
+			t.Fatal(valid, result, err)
+		}
+	}
+}
+
+func TestEnvelopeTime(t *testing.T) {
+	serialized, err := json.Marshal(model.Envelope{DeviceId: "device1", Value: 1})
+	if err != nil || string(serialized) != `{"device_id":"device1","value":1}` {
+		t.Fatal(string(serialized), err)
+	}
+	eventTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	serialized, err = json.Marshal(model.Envelope{DeviceId: "device1", Value: 1, Time: eventTime})
+	if err != nil || !strings.Contains(string(serialized), `"time":"2020-01-02T03:04:05Z"`) {
+		t.Fatal(string(serialized), err)
+	}
+	envelope := model.Envelope{}
+	err = json.Unmarshal(serialized, &envelope)
+	if err != nil || !envelope.Time.Equal(eventTime) || envelope.DeviceId != "device1" {
+		t.Fatal(envelope, err)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package platform_connector_lib
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"sync"
+)
+
+var ErrShuttingDown = errors.New("connector is shutting down")
+
+//tracks commands which are currently handled by a command handler
+type inflightCommands struct {
+	mux      sync.Mutex
+	next     uint64
+	commands map[uint64]model.ProtocolMsg
+	closed   bool
+	idle     chan struct{} //closed when closed is set and no command is pending
+}
+
+func newInflightCommands() *inflightCommands {
+	return &inflightCommands{commands: map[uint64]model.ProtocolMsg{}, idle: make(chan struct{})}
+}
+
+//returns ErrShuttingDown after wait() has been called
+func (this *inflightCommands) add(msg model.ProtocolMsg) (id uint64, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.closed {
+		return 0, ErrShuttingDown
+	}
+	this.next++
+	id = this.next
+	this.commands[id] = msg
+	return id, nil
+}
+
+func (this *inflightCommands) done(id uint64) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, ok := this.commands[id]; ok {
+		delete(this.commands, id)
+		this.signalIdle()
+	}
+}
+
+func (this *inflightCommands) pending() (result []model.ProtocolMsg) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, msg := range this.commands {
+		result = append(result, msg)
+	}
+	return
+}
+
+//stops accepting new commands and waits until all tracked commands are done or ctx is done; returns ctx.Err() if commands are still pending
+func (this *inflightCommands) wait(ctx context.Context) error {
+	this.mux.Lock()
+	if !this.closed {
+		this.closed = true
+		this.signalIdle()
+	}
+	this.mux.Unlock()
+	select {
+	case <-this.idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//must be called with locked mux
+func (this *inflightCommands) signalIdle() {
+	if this.closed && len(this.commands) == 0 {
+		select {
+		case <-this.idle:
+		default:
+			close(this.idle)
+		}
+	}
+}
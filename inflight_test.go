@@ -0,0 +1,51 @@
+package platform_connector_lib
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"testing"
+	"time"
+)
+
+func TestInflightWait(t *testing.T) {
+	inflight := newInflightCommands()
+	id, err := inflight.add(model.ProtocolMsg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		inflight.done(id)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = inflight.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = inflight.add(model.ProtocolMsg{}); err != ErrShuttingDown {
+		t.Fatal(err)
+	}
+}
+
+func TestInflightWaitTimeout(t *testing.T) {
+	inflight := newInflightCommands()
+	_, err := inflight.add(model.ProtocolMsg{TaskInfo: model.TaskInfo{TaskId: "pending"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err = inflight.wait(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	if pending := inflight.pending(); len(pending) != 1 || pending[0].TaskInfo.TaskId != "pending" {
+		t.Fatal(pending)
+	}
+}
+
+func TestInflightWaitWithoutCommands(t *testing.T) {
+	inflight := newInflightCommands()
+	if err := inflight.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -6,77 +6,136 @@ import (
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
 	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"log"
+	"sync/atomic"
+	"time"
 )
 
 type PreparedCache struct {
 	iot                  *Iot
-	cache                *cache.Cache
+	deviceCache          *cache.Cache
+	deviceTypeCache      *cache.Cache
 	deviceExpiration     int32
 	deviceTypeExpiration int32
+	keys                 *keyIndex
+	counter              *cacheCounter
+	negativeCaching      bool
 	Debug                bool
 }
 
+type CacheStatistics struct {
+	NegativeHits    uint64 //GetDeviceByLocalId() calls answered by a cached security.ErrorNotFound
+	NegativeEntries uint64 //cached security.ErrorNotFound results of GetDeviceByLocalId()
+}
+
+type cacheCounter struct {
+	negativeHits    uint64
+	negativeEntries uint64
+}
+
+func (this *cacheCounter) statistics() CacheStatistics {
+	return CacheStatistics{
+		NegativeHits:    atomic.LoadUint64(&this.negativeHits),
+		NegativeEntries: atomic.LoadUint64(&this.negativeEntries),
+	}
+}
+
 type Cache struct {
 	iot                  *Iot
-	cache                *cache.Cache
+	deviceCache          *cache.Cache
+	deviceTypeCache      *cache.Cache
 	deviceExpiration     int32
 	deviceTypeExpiration int32
+	keys                 *keyIndex
+	counter              *cacheCounter
+	negativeCaching      bool
 	token                security.JwtToken
 	debug                bool
 	protocol             map[string]model.Protocol
 }
 
 func NewCache(iot *Iot, deviceExpiration int32, deviceTypeExpiration int32, memcachedServer ...string) *PreparedCache {
-	return &PreparedCache{iot: iot, deviceExpiration: deviceExpiration, deviceTypeExpiration: deviceTypeExpiration, cache: cache.New(memcachedServer...)}
+	return NewCacheWithBackend(iot, deviceExpiration, deviceTypeExpiration, cache.NewMemcachedBackend(memcachedServer...))
+}
+
+func NewCacheWithBackend(iot *Iot, deviceExpiration int32, deviceTypeExpiration int32, backend cache.Backend) *PreparedCache {
+	return NewCacheWithOptions(iot, deviceExpiration, deviceTypeExpiration, backend, cache.Options{}, cache.Options{})
+}
+
+//devices and device-types share one cache instance (and its l1 memory) if deviceOptions and deviceTypeOptions are equal.
+//unknown local device ids are cached for deviceOptions.NegativeExpiration seconds;
+//device-types are never cached negative, so NegativeExpiration is ignored when comparing the options
+func NewCacheWithOptions(iot *Iot, deviceExpiration int32, deviceTypeExpiration int32, backend cache.Backend, deviceOptions cache.Options, deviceTypeOptions cache.Options) *PreparedCache {
+	deviceCache := cache.NewWithOptions(backend, deviceOptions)
+	deviceTypeCache := deviceCache
+	deviceTypeOptions.NegativeExpiration = deviceOptions.NegativeExpiration
+	if deviceTypeOptions != deviceOptions {
+		deviceTypeCache = cache.NewWithOptions(backend, deviceTypeOptions)
+	}
+	return &PreparedCache{iot: iot, deviceExpiration: deviceExpiration, deviceTypeExpiration: deviceTypeExpiration, deviceCache: deviceCache, deviceTypeCache: deviceTypeCache, keys: newKeyIndex(time.Duration(deviceExpiration) * time.Second), counter: &cacheCounter{}, negativeCaching: deviceOptions.NegativeExpiration > 0}
+}
+
+func (this *PreparedCache) Statistics() CacheStatistics {
+	return this.counter.statistics()
 }
 
 func (this *PreparedCache) WithToken(token security.JwtToken) *Cache {
-	return &Cache{iot: this.iot, deviceExpiration: this.deviceExpiration, deviceTypeExpiration: this.deviceTypeExpiration, debug: this.Debug, cache: this.cache, token: token, protocol: map[string]model.Protocol{}}
+	return &Cache{iot: this.iot, deviceExpiration: this.deviceExpiration, deviceTypeExpiration: this.deviceTypeExpiration, debug: this.Debug, deviceCache: this.deviceCache, deviceTypeCache: this.deviceTypeCache, keys: this.keys, counter: this.counter, negativeCaching: this.negativeCaching, token: token, protocol: map[string]model.Protocol{}}
 }
 
 func (this *Cache) GetDevice(id string) (result model.Device, err error) {
-	if this.deviceExpiration != 0 {
-		result, err = this.getDeviceFromCache(this.token, id)
-		if err == nil {
-			return
-		}
-		if err != cache.ErrNotFound {
-			log.Println("ERROR: Cache.GetDeviceFromCache() ", err)
+	pl, err := this.token.GetPayload()
+	if this.deviceExpiration == 0 || err != nil {
+		return this.iot.GetDevice(id, this.token)
+	}
+	key := deviceKey(pl.UserId, id)
+	value, err := this.deviceCache.GetOrLoad(key, func() ([]byte, error) {
+		device, err := this.iot.GetDevice(id, this.token)
+		if err != nil {
+			return nil, err
 		}
-	}
-	result, err = this.iot.GetDevice(id, this.token)
+		this.keys.add(device.Id, key)
+		return json.Marshal(device)
+	}, this.deviceExpiration)
 	if err != nil {
-		return
-	}
-	if this.deviceExpiration != 0 {
-		this.saveDeviceToCache(this.token, result)
+		return result, err
 	}
+	err = json.Unmarshal(value, &result)
 	return
 }
 
 func (this *Cache) GetDeviceByLocalId(deviceUrl string) (result model.Device, err error) {
-	if this.deviceExpiration != 0 {
-		result, err = this.getDeviceUrlToIotDeviceFromCache(this.token, deviceUrl)
-		if err == nil {
-			return
+	pl, err := this.token.GetPayload()
+	if this.deviceExpiration == 0 || err != nil {
+		return this.iot.GetDeviceByLocalId(deviceUrl, this.token)
+	}
+	key := deviceUrlKey(pl.UserId, deviceUrl)
+	value, err := this.deviceCache.GetOrLoad(key, func() ([]byte, error) {
+		device, err := this.iot.GetDeviceByLocalId(deviceUrl, this.token)
+		if err == security.ErrorNotFound && this.negativeCaching {
+			atomic.AddUint64(&this.counter.negativeEntries, 1)
+			return nil, cache.Negative(err)
 		}
-		if err != cache.ErrNotFound {
-			log.Println("ERROR: Cache.DeviceUrlToIotDevice() ", err)
+		if err != nil {
+			return nil, err
 		}
+		this.keys.add(device.Id, key)
+		return json.Marshal(device)
+	}, this.deviceExpiration)
+	if err == cache.ErrNegative {
+		atomic.AddUint64(&this.counter.negativeHits, 1)
+		return result, security.ErrorNotFound
 	}
-	result, err = this.iot.GetDeviceByLocalId(deviceUrl, this.token)
 	if err != nil {
-		return
-	}
-	if this.deviceExpiration != 0 {
-		this.saveDeviceUrlToIotDeviceToCache(this.token, deviceUrl, result)
+		return result, err
 	}
+	err = json.Unmarshal(value, &result)
 	return
 }
 
 func (this *Cache) CreateDevice(device model.Device) (result model.Device, err error) {
 	result, err = this.iot.CreateDevice(device, this.token)
 	if err == nil {
+		//replaces a negative entry of the local id
 		this.saveDeviceUrlToIotDeviceToCache(this.token, device.LocalId, result)
 		this.saveDeviceToCache(this.token, result)
 	}
@@ -92,35 +151,23 @@ func (this *Cache) EnsureLocalDeviceExistence(device model.Device) (result model
 }
 
 func (this *Cache) GetDeviceType(id string) (result model.DeviceType, err error) {
-	if this.deviceTypeExpiration != 0 {
-		result, err = this.getDeviceTypeFromCache(this.token, id)
-		if err == nil {
-			return
-		}
-		if err != cache.ErrNotFound {
-			log.Println("ERROR: Cache.GetDeviceType() ", err)
-		}
+	if this.deviceTypeExpiration == 0 {
+		return this.iot.GetDeviceType(id, this.token)
 	}
-	result, err = this.iot.GetDeviceType(id, this.token)
-	if err != nil {
-		return
-	}
-	if this.deviceTypeExpiration != 0 {
-		this.saveDeviceTypeToCache(this.token, result)
-	}
-	return
-}
-
-func (this *Cache) getDeviceFromCache(token security.JwtToken, id string) (device model.Device, err error) {
-	pl, err := token.GetPayload()
+	value, err := this.deviceTypeCache.GetOrLoad(deviceTypeKey(id), func() ([]byte, error) {
+		dt, err := this.iot.GetDeviceType(id, this.token)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(dt)
+	}, this.deviceTypeExpiration)
 	if err != nil {
-		return device, err
+		return result, err
 	}
-	item, err := this.cache.Get("device." + pl.UserId + "." + id)
-	if err != nil {
-		return device, err
+	if this.debug {
+		log.Println("DEBUG: GetDeviceType()", deviceTypeKey(id), string(value))
 	}
-	err = json.Unmarshal(item.Value, &device)
+	err = json.Unmarshal(value, &result)
 	return
 }
 
@@ -135,41 +182,9 @@ func (this *Cache) saveDeviceToCache(token security.JwtToken, instance model.Dev
 		log.Println("WARNING: saveDeviceToCache() unable to marshal instance", err)
 		return
 	}
-	this.cache.Set("device."+pl.UserId+"."+instance.Id, value, this.deviceExpiration)
-}
-
-func (this *Cache) getDeviceTypeFromCache(token security.JwtToken, id string) (dt model.DeviceType, err error) {
-	item, err := this.cache.Get("dt." + id)
-	if err != nil {
-		return dt, err
-	}
-	if this.debug {
-		log.Println("DEBUG: getDeviceTypeFromCache()", "dt."+id, string(item.Value), err)
-	}
-	err = json.Unmarshal(item.Value, &dt)
-	return
-}
-
-func (this *Cache) saveDeviceTypeToCache(token security.JwtToken, deviceType model.DeviceType) {
-	value, err := json.Marshal(deviceType)
-	if err != nil {
-		log.Println("WARNING: saveDeviceTypeToCache() unable to marshal instance", err)
-		return
-	}
-	this.cache.Set("dt."+deviceType.Id, value, this.deviceTypeExpiration)
-}
-
-func (this *Cache) getDeviceUrlToIotDeviceFromCache(token security.JwtToken, deviceUrl string) (entities model.Device, err error) {
-	pl, err := token.GetPayload()
-	if err != nil {
-		return entities, err
-	}
-	item, err := this.cache.Get("device_url." + pl.UserId + "." + deviceUrl)
-	if err != nil {
-		return entities, err
-	}
-	err = json.Unmarshal(item.Value, &entities)
-	return
+	key := deviceKey(pl.UserId, instance.Id)
+	this.keys.add(instance.Id, key)
+	this.deviceCache.Set(key, value, this.deviceExpiration)
 }
 
 func (this *Cache) saveDeviceUrlToIotDeviceToCache(token security.JwtToken, deviceUrl string, entities model.Device) {
@@ -183,7 +198,9 @@ func (this *Cache) saveDeviceUrlToIotDeviceToCache(token security.JwtToken, devi
 		log.Println("WARNING: saveDeviceToCache() unable to marshal entities", err)
 		return
 	}
-	this.cache.Set("device_url."+pl.UserId+"."+deviceUrl, value, this.deviceExpiration)
+	key := deviceUrlKey(pl.UserId, deviceUrl)
+	this.keys.add(entities.Id, key)
+	this.deviceCache.Set(key, value, this.deviceExpiration)
 }
 
 func (this *Cache) GetProtocol(id string) (protocol model.Protocol, err error) {
@@ -197,4 +214,16 @@ func (this *Cache) GetProtocol(id string) (protocol model.Protocol, err error) {
 	}
 	this.protocol[id] = protocol
 	return protocol, err
-}
\ No newline at end of file
+}
+
+func deviceKey(userId string, deviceId string) string {
+	return "device." + userId + "." + deviceId
+}
+
+func deviceUrlKey(userId string, localId string) string {
+	return "device_url." + userId + "." + localId
+}
+
+func deviceTypeKey(deviceTypeId string) string {
+	return "dt." + deviceTypeId
+}
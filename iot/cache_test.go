@@ -0,0 +1,117 @@
+package iot
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/SENERGY-Platform/platform-connector-lib/cache"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testToken(userId string) security.JwtToken {
+	payload, _ := json.Marshal(map[string]string{"sub": userId})
+	return security.JwtToken("Bearer header." + base64.RawURLEncoding.EncodeToString(payload) + ".signature")
+}
+
+func TestConcurrentDeviceMiss(t *testing.T) {
+	requests := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(100 * time.Millisecond)
+		json.NewEncoder(writer).Encode(model.Device{Id: "device1", LocalId: "local1", DeviceTypeId: "dt1"})
+	}))
+	defer server.Close()
+
+	prepared := NewCacheWithBackend(New(server.URL, server.URL), 60, 60, cache.NewLocalBackend(1024*1024))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			device, err := prepared.WithToken(testToken("user1")).GetDevice("device1")
+			if err != nil || device.LocalId != "local1" {
+				t.Error(device, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if requests != 1 {
+		t.Fatal(requests)
+	}
+}
+
+func TestNegativeLocalIdCache(t *testing.T) {
+	requests := int32(0)
+	created := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if request.Method == http.MethodPost {
+			atomic.StoreInt32(&created, 1)
+			json.NewEncoder(writer).Encode(model.Device{Id: "device1", LocalId: "local1", DeviceTypeId: "dt1"})
+			return
+		}
+		if atomic.LoadInt32(&created) == 0 {
+			http.Error(writer, "not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(writer).Encode(model.Device{Id: "device1", LocalId: "local1", DeviceTypeId: "dt1"})
+	}))
+	defer server.Close()
+
+	options := cache.Options{L1Size: 1024 * 1024}
+	prepared := NewCacheWithOptions(New(server.URL, server.URL), 60, 60, cache.NewLocalBackend(1024*1024), cache.Options{L1Size: 1024 * 1024, NegativeExpiration: 60}, options)
+	if prepared.deviceCache != prepared.deviceTypeCache {
+		t.Fatal("negative caching of devices should not allocate a second l1 cache")
+	}
+	c := prepared.WithToken(testToken("user1"))
+	for i := 0; i < 5; i++ {
+		_, err := c.GetDeviceByLocalId("local1")
+		if err != security.ErrorNotFound {
+			t.Fatal(err)
+		}
+	}
+	if requests != 1 {
+		t.Fatal(requests)
+	}
+	if statistics := prepared.Statistics(); statistics.NegativeHits != 4 || statistics.NegativeEntries != 1 {
+		t.Fatal(statistics)
+	}
+
+	device, err := c.EnsureLocalDeviceExistence(model.Device{LocalId: "local1", DeviceTypeId: "dt1"})
+	if err != nil || device.Id != "device1" {
+		t.Fatal(device, err)
+	}
+	device, err = c.GetDeviceByLocalId("local1")
+	if err != nil || device.Id != "device1" {
+		t.Fatal(device, err)
+	}
+	if requests != 2 {
+		t.Fatal(requests)
+	}
+}
+
+func TestNegativeLocalIdCacheDisabled(t *testing.T) {
+	requests := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(writer, "not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	prepared := NewCacheWithBackend(New(server.URL, server.URL), 60, 60, cache.NewLocalBackend(1024*1024))
+	c := prepared.WithToken(testToken("user1"))
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetDeviceByLocalId("local1"); err != security.ErrorNotFound {
+			t.Fatal(err)
+		}
+	}
+	if requests != 3 || prepared.Statistics() != (CacheStatistics{}) {
+		t.Fatal(requests, prepared.Statistics())
+	}
+}
@@ -0,0 +1,16 @@
+package iot
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
+	"log"
+	"net/url"
+)
+
+func (this *Iot) GetCharacteristic(id string, token security.JwtToken) (characteristic model.Characteristic, err error) {
+	err = token.GetJSON(this.repo_url+"/characteristics/"+url.QueryEscape(id), &characteristic)
+	if err != nil {
+		log.Println("ERROR on GetCharacteristic()", err)
+	}
+	return characteristic, err
+}
@@ -0,0 +1,172 @@
+package iot
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/platform-connector-lib/kafka"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"log"
+	"sync"
+	"time"
+)
+
+//device and device-type commands of the platform (e.g. published by the device-manager on PUT or DELETE)
+type DeviceCommand struct {
+	Command string       `json:"command"`
+	Id      string       `json:"id"`
+	Owner   string       `json:"owner"`
+	Device  model.Device `json:"device"`
+}
+
+type DeviceTypeCommand struct {
+	Command    string           `json:"command"`
+	Id         string           `json:"id"`
+	Owner      string           `json:"owner"`
+	DeviceType model.DeviceType `json:"device_type"`
+}
+
+//remembers the device cache keys written by this instance, because they contain the user id of the token.
+//keys are forgotten when their cache entries expire
+type keyIndex struct {
+	mux        sync.Mutex
+	keys       map[string]map[string]time.Time
+	expiration time.Duration
+	lastPrune  time.Time
+}
+
+func newKeyIndex(expiration time.Duration) *keyIndex {
+	return &keyIndex{keys: map[string]map[string]time.Time{}, expiration: expiration, lastPrune: time.Now()}
+}
+
+func (this *keyIndex) add(deviceId string, key string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	now := time.Now()
+	if this.keys[deviceId] == nil {
+		this.keys[deviceId] = map[string]time.Time{}
+	}
+	this.keys[deviceId][key] = now.Add(this.expiration)
+	if now.Sub(this.lastPrune) > this.expiration {
+		this.prune(now)
+	}
+}
+
+//must be called with locked mux
+func (this *keyIndex) prune(now time.Time) {
+	for deviceId, keys := range this.keys {
+		for key, expiration := range keys {
+			if now.After(expiration) {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(this.keys, deviceId)
+		}
+	}
+	this.lastPrune = now
+}
+
+func (this *keyIndex) remove(deviceId string) (keys []string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for key := range this.keys[deviceId] {
+		keys = append(keys, key)
+	}
+	delete(this.keys, deviceId)
+	return keys
+}
+
+func (this *keyIndex) size() (result int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, keys := range this.keys {
+		result += len(keys)
+	}
+	return result
+}
+
+//removes all entries of the device which have been cached by this instance (e.g. for other users than the owner).
+//entries cached by other instances or before a restart are only removed by InvalidateUserDevice()
+func (this *PreparedCache) InvalidateDevice(deviceId string) {
+	for _, key := range this.keys.remove(deviceId) {
+		this.deviceCache.Remove(key)
+	}
+}
+
+//removes the entries of the device cached for userId, independent of the instance which cached them; localId is optional
+func (this *PreparedCache) InvalidateUserDevice(userId string, deviceId string, localId string) {
+	this.deviceCache.Remove(deviceKey(userId, deviceId))
+	if localId != "" {
+		this.deviceCache.Remove(deviceUrlKey(userId, localId))
+	}
+}
+
+func (this *PreparedCache) InvalidateDeviceType(deviceTypeId string) {
+	this.deviceTypeCache.Remove(deviceTypeKey(deviceTypeId))
+}
+
+//reads deviceTopic and deviceTypeTopic and invalidates the changed or deleted devices and device-types.
+//the topics are read from the latest offset without consumer group, so every instance sees every message; they are not created if missing.
+//empty topics are not read. the readers run until ctx is done
+func (this *PreparedCache) StartInvalidation(ctx context.Context, cluster kafka.Cluster, deviceTopic string, deviceTypeTopic string) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		//stop the device topic reader if the device-type topic can not be read
+		if err != nil {
+			cancel()
+		}
+	}()
+	if deviceTopic != "" {
+		err = kafka.Tail(ctx, cluster, deviceTopic, func(topic string, msg []byte, time time.Time) {
+			this.handleDeviceCommand(msg)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if deviceTypeTopic != "" {
+		err = kafka.Tail(ctx, cluster, deviceTypeTopic, func(topic string, msg []byte, time time.Time) {
+			this.handleDeviceTypeCommand(msg)
+		})
+	}
+	return err
+}
+
+func (this *PreparedCache) handleDeviceCommand(msg []byte) {
+	command := DeviceCommand{}
+	err := json.Unmarshal(msg, &command)
+	if err != nil {
+		log.Println("WARNING: unable to parse device command for cache invalidation", err, string(msg))
+		return
+	}
+	if command.Id == "" {
+		command.Id = command.Device.Id
+	}
+	if this.Debug {
+		log.Println("DEBUG: invalidate device", command.Command, command.Id)
+	}
+	//the keys of the owner are computed from the command, because the key index only knows entries written by this instance
+	if command.Owner != "" {
+		this.InvalidateUserDevice(command.Owner, command.Id, command.Device.LocalId)
+	}
+	if command.Device.DeviceTypeId != "" {
+		this.InvalidateDeviceType(command.Device.DeviceTypeId)
+	}
+	this.InvalidateDevice(command.Id)
+}
+
+func (this *PreparedCache) handleDeviceTypeCommand(msg []byte) {
+	command := DeviceTypeCommand{}
+	err := json.Unmarshal(msg, &command)
+	if err != nil {
+		log.Println("WARNING: unable to parse device-type command for cache invalidation", err, string(msg))
+		return
+	}
+	if command.Id == "" {
+		command.Id = command.DeviceType.Id
+	}
+	if this.Debug {
+		log.Println("DEBUG: invalidate device-type", command.Command, command.Id)
+	}
+	this.InvalidateDeviceType(command.Id)
+}
@@ -0,0 +1,128 @@
+package iot
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/platform-connector-lib/cache"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeviceCommandInvalidation(t *testing.T) {
+	requests := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if strings.Contains(request.URL.Path, "device-types") {
+			json.NewEncoder(writer).Encode(model.DeviceType{Id: "dt1", Name: "dt"})
+			return
+		}
+		json.NewEncoder(writer).Encode(model.Device{Id: "device1", LocalId: "local1", DeviceTypeId: "dt1"})
+	}))
+	defer server.Close()
+
+	prepared := NewCacheWithBackend(New(server.URL, server.URL), 60, 60, cache.NewLocalBackend(1024*1024))
+	get := func() {
+		c := prepared.WithToken(testToken("user1"))
+		if _, err := c.GetDevice("device1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetDeviceByLocalId("local1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetDeviceType("dt1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get()
+	get()
+	if requests != 3 {
+		t.Fatal(requests)
+	}
+
+	prepared.handleDeviceCommand([]byte(`{"command":"PUT","id":"device1","owner":"user1","device":{"id":"device1","local_id":"local1"}}`))
+	get()
+	if requests != 5 {
+		t.Fatal(requests)
+	}
+
+	prepared.handleDeviceTypeCommand([]byte(`{"command":"DELETE","id":"dt1"}`))
+	get()
+	if requests != 6 {
+		t.Fatal(requests)
+	}
+}
+
+func TestInvalidateDeviceWithoutOwner(t *testing.T) {
+	requests := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		json.NewEncoder(writer).Encode(model.Device{Id: "device1", LocalId: "local1", DeviceTypeId: "dt1"})
+	}))
+	defer server.Close()
+
+	prepared := NewCacheWithBackend(New(server.URL, server.URL), 60, 60, cache.NewLocalBackend(1024*1024))
+	for _, user := range []string{"user1", "user2"} {
+		if _, err := prepared.WithToken(testToken(user)).GetDeviceByLocalId("local1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prepared.InvalidateDevice("device1")
+	for _, user := range []string{"user1", "user2"} {
+		if _, err := prepared.WithToken(testToken(user)).GetDeviceByLocalId("local1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 4 {
+		t.Fatal(requests)
+	}
+}
+
+func TestInvalidationOfOtherInstances(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if strings.Contains(request.URL.Path, "device-types") {
+			json.NewEncoder(writer).Encode(model.DeviceType{Id: "dt1", Name: "dt"})
+			return
+		}
+		json.NewEncoder(writer).Encode(model.Device{Id: "device1", LocalId: "local1", DeviceTypeId: "dt1"})
+	}))
+	defer server.Close()
+
+	backend := cache.NewLocalBackend(1024 * 1024)
+	c := NewCacheWithBackend(New(server.URL, server.URL), 60, 60, backend).WithToken(testToken("user1"))
+	if _, err := c.GetDevice("device1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetDeviceByLocalId("local1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetDeviceType("dt1"); err != nil {
+		t.Fatal(err)
+	}
+
+	//an other instance (or this instance after a restart) does not know the cached keys
+	other := NewCacheWithBackend(New(server.URL, server.URL), 60, 60, backend)
+	other.handleDeviceCommand([]byte(`{"command":"PUT","id":"device1","owner":"user1","device":{"id":"device1","local_id":"local1","device_type_id":"dt1"}}`))
+	for _, key := range []string{deviceKey("user1", "device1"), deviceUrlKey("user1", "local1"), deviceTypeKey("dt1")} {
+		if _, err := backend.Get(key); err != cache.ErrNotFound {
+			t.Fatal(key, err)
+		}
+	}
+}
+
+func TestKeyIndexPrune(t *testing.T) {
+	index := newKeyIndex(20 * time.Millisecond)
+	index.add("device1", "device.user1.device1")
+	index.add("device1", "device_url.user1.local1")
+	time.Sleep(50 * time.Millisecond)
+	index.add("device2", "device.user1.device2")
+	if size := index.size(); size != 1 {
+		t.Fatal(size)
+	}
+	if keys := index.remove("device2"); len(keys) != 1 || keys[0] != "device.user1.device2" {
+		t.Fatal(keys)
+	}
+}
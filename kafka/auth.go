@@ -0,0 +1,138 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/Shopify/sarama"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	xdgscram "github.com/xdg/scram"
+	"io/ioutil"
+)
+
+const (
+	SaslPlain       = "PLAIN"
+	SaslScramSha256 = "SCRAM-SHA-256"
+	SaslScramSha512 = "SCRAM-SHA-512"
+)
+
+type TlsConfig struct {
+	Enabled            bool
+	CaFile             string //optional pem file; the system cert pool is used if empty
+	CertFile           string //optional pem file with the client certificate
+	KeyFile            string //optional pem file with the client key
+	InsecureSkipVerify bool
+}
+
+type SaslConfig struct {
+	Mechanism string //SaslPlain, SaslScramSha256, SaslScramSha512 or empty to disable sasl
+	User      string
+	Password  string
+}
+
+//returns nil if tls is disabled
+func (this TlsConfig) tlsConfig() (result *tls.Config, err error) {
+	if !this.Enabled {
+		return nil, nil
+	}
+	result = &tls.Config{InsecureSkipVerify: this.InsecureSkipVerify}
+	if this.CaFile != "" {
+		ca, err := ioutil.ReadFile(this.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		result.RootCAs = x509.NewCertPool()
+		if !result.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("unable to parse kafka tls ca file " + this.CaFile)
+		}
+	}
+	if this.CertFile != "" || this.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(this.CertFile, this.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+	return result, nil
+}
+
+//returns nil if sasl is disabled
+func (this SaslConfig) mechanism() (sasl.Mechanism, error) {
+	switch this.Mechanism {
+	case "":
+		return nil, nil
+	case SaslPlain:
+		return plain.Mechanism{Username: this.User, Password: this.Password}, nil
+	case SaslScramSha256:
+		return scram.Mechanism(scram.SHA256, this.User, this.Password)
+	case SaslScramSha512:
+		return scram.Mechanism(scram.SHA512, this.User, this.Password)
+	default:
+		return nil, errors.New("unknown kafka sasl mechanism " + this.Mechanism)
+	}
+}
+
+func (this SaslConfig) applyToSarama(config *sarama.Config) error {
+	switch this.Mechanism {
+	case "":
+		return nil
+	case SaslPlain:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case SaslScramSha256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClient = &scramClient{HashGeneratorFcn: xdgscram.SHA256}
+	case SaslScramSha512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClient = &scramClient{HashGeneratorFcn: xdgscram.HashGeneratorFcn(sha512.New)}
+	default:
+		return errors.New("unknown kafka sasl mechanism " + this.Mechanism)
+	}
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Handshake = true
+	config.Net.SASL.User = this.User
+	config.Net.SASL.Password = this.Password
+	return nil
+}
+
+//implements sarama.SCRAMClient
+type scramClient struct {
+	*xdgscram.Client
+	*xdgscram.ClientConversation
+	xdgscram.HashGeneratorFcn
+}
+
+func (this *scramClient) Begin(userName, password, authzID string) (err error) {
+	this.Client, err = this.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	this.ClientConversation = this.Client.NewConversation()
+	return nil
+}
+
+func (this *scramClient) Step(challenge string) (response string, err error) {
+	return this.ClientConversation.Step(challenge)
+}
+
+func (this *scramClient) Done() bool {
+	return this.ClientConversation.Done()
+}
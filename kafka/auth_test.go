@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"testing"
+)
+
+func TestSaslConfig(t *testing.T) {
+	for _, mechanism := range []string{SaslPlain, SaslScramSha256, SaslScramSha512} {
+		cluster := Cluster{Sasl: SaslConfig{Mechanism: mechanism, User: "user", Password: "pw"}}
+		config, err := cluster.saramaConfig()
+		if err != nil {
+			t.Fatal(mechanism, err)
+		}
+		if !config.Net.SASL.Enable || string(config.Net.SASL.Mechanism) != mechanism || config.Net.SASL.User != "user" {
+			t.Fatal(mechanism, config.Net.SASL)
+		}
+		if mechanism != SaslPlain && config.Net.SASL.SCRAMClient == nil {
+			t.Fatal(mechanism, "missing scram client")
+		}
+		err = config.Validate()
+		if err != nil {
+			t.Fatal(mechanism, err)
+		}
+		dialer, err := cluster.dialer()
+		if err != nil {
+			t.Fatal(mechanism, err)
+		}
+		if dialer.SASLMechanism == nil || dialer.SASLMechanism.Name() != mechanism {
+			t.Fatal(mechanism, dialer.SASLMechanism)
+		}
+	}
+}
+
+func TestSaslConfigDisabled(t *testing.T) {
+	config, err := Cluster{}.saramaConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Net.SASL.Enable || config.Net.TLS.Enable {
+		t.Fatal(config.Net)
+	}
+	dialer, err := Cluster{}.dialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dialer.SASLMechanism != nil || dialer.TLS != nil {
+		t.Fatal(dialer)
+	}
+}
+
+func TestSaslConfigUnknown(t *testing.T) {
+	_, err := Cluster{Sasl: SaslConfig{Mechanism: "foo"}}.saramaConfig()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	_, err = Cluster{Sasl: SaslConfig{Mechanism: "foo"}}.dialer()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestTlsConfig(t *testing.T) {
+	config, err := Cluster{Tls: TlsConfig{Enabled: true, InsecureSkipVerify: true}}.saramaConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Net.TLS.Enable || config.Net.TLS.Config == nil || !config.Net.TLS.Config.InsecureSkipVerify {
+		t.Fatal(config.Net.TLS)
+	}
+	_, err = Cluster{Tls: TlsConfig{Enabled: true, CaFile: "does/not/exist.pem"}}.dialer()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
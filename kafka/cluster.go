@@ -0,0 +1,182 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"errors"
+	"github.com/Shopify/sarama"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+//describes how the kafka cluster is found and how connections to the brokers are secured.
+//if Brokers is set, brokers, controller and topics are managed with the kafka admin protocol, otherwise with zookeeper
+type Cluster struct {
+	ZookeeperUrl string   //host1:2181,host2:2181/chroot
+	Brokers      []string //bootstrap brokers host1:9092,host2:9092
+	Tls          TlsConfig
+	Sasl         SaslConfig
+	Topics       TopicPolicies //used to create topics with InitTopic() and EnsureTopic()
+}
+
+func (this Cluster) usesZookeeper() bool {
+	return len(this.Brokers) == 0
+}
+
+func (this Cluster) saramaConfig() (result *sarama.Config, err error) {
+	result = sarama.NewConfig()
+	result.Version = sarama.V2_2_0_0
+	tlsConfig, err := this.Tls.tlsConfig()
+	if err != nil {
+		return result, err
+	}
+	if tlsConfig != nil {
+		result.Net.TLS.Enable = true
+		result.Net.TLS.Config = tlsConfig
+	}
+	err = this.Sasl.applyToSarama(result)
+	return result, err
+}
+
+//dialer for kafka-go connections
+func (this Cluster) dialer() (result *kafka.Dialer, err error) {
+	result = &kafka.Dialer{
+		Timeout:   10 * time.Second,
+		DualStack: true,
+	}
+	result.TLS, err = this.Tls.tlsConfig()
+	if err != nil {
+		return result, err
+	}
+	result.SASLMechanism, err = this.Sasl.mechanism()
+	return result, err
+}
+
+func (this Cluster) GetBroker() (brokers []string, err error) {
+	if this.usesZookeeper() {
+		return getBroker(this.ZookeeperUrl)
+	}
+	config, err := this.saramaConfig()
+	if err != nil {
+		return brokers, err
+	}
+	client, err := sarama.NewClient(this.Brokers, config)
+	if err != nil {
+		return brokers, err
+	}
+	defer client.Close()
+	for _, broker := range client.Brokers() {
+		brokers = append(brokers, broker.Addr())
+	}
+	return brokers, nil
+}
+
+func (this Cluster) GetController() (controller string, err error) {
+	if this.usesZookeeper() {
+		return getKafkaController(this.ZookeeperUrl)
+	}
+	config, err := this.saramaConfig()
+	if err != nil {
+		return controller, err
+	}
+	client, err := sarama.NewClient(this.Brokers, config)
+	if err != nil {
+		return controller, err
+	}
+	defer client.Close()
+	broker, err := client.Controller()
+	if err != nil {
+		return controller, err
+	}
+	return broker.Addr(), nil
+}
+
+//creates the topics as described by Cluster.Topics; existing topics which do not satisfy their policy are reported in the background
+//(see TopicPolicies.ViolationHandler)
+func (this Cluster) InitTopic(topics ...string) (err error) {
+	for _, topic := range topics {
+		err = this.InitTopicWithPolicy(this.Topics.Get(topic), topic)
+		if err != nil {
+			return err
+		}
+	}
+	go this.reportTopicPolicyViolations(topics...)
+	return nil
+}
+
+func (this Cluster) InitTopicWithConfig(numPartitions int, replicationFactor int, topics ...string) (err error) {
+	return this.InitTopicWithPolicy(TopicPolicy{Partitions: numPartitions, ReplicationFactor: replicationFactor}, topics...)
+}
+
+//creates the topics if they do not exist; existing topics are not changed. partitions and replication factor < 1 are handled as 1
+func (this Cluster) InitTopicWithPolicy(policy TopicPolicy, topics ...string) (err error) {
+	policy = policy.normalized()
+	if this.usesZookeeper() {
+		return this.initTopicWithZookeeper(policy, topics...)
+	}
+	config, err := this.saramaConfig()
+	if err != nil {
+		return err
+	}
+	admin, err := sarama.NewClusterAdmin(this.Brokers, config)
+	if err != nil {
+		log.Println("ERROR: unable to connect to kafka cluster", err)
+		return err
+	}
+	defer admin.Close()
+	for _, topic := range topics {
+		err = admin.CreateTopic(topic, &sarama.TopicDetail{
+			NumPartitions:     int32(policy.Partitions),
+			ReplicationFactor: int16(policy.ReplicationFactor),
+			ConfigEntries:     policy.configEntries(),
+		}, false)
+		if isTopicAlreadyExists(err) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isTopicAlreadyExists(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == sarama.ErrTopicAlreadyExists {
+		return true
+	}
+	topicErr := &sarama.TopicError{}
+	return errors.As(err, &topicErr) && topicErr.Err == sarama.ErrTopicAlreadyExists
+}
+
+func (this Cluster) EnsureTopic(topic string, knownTopics *map[string]bool) (err error) {
+	if (*knownTopics)[topic] {
+		return nil
+	}
+	err = this.InitTopic(topic)
+	if err != nil {
+		log.Println("ERROR:", err)
+		debug.PrintStack()
+		return err
+	}
+	(*knownTopics)[topic] = true
+	return
+}
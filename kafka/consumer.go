@@ -27,42 +27,111 @@ import (
 )
 
 func NewConsumer(zk string, groupid string, topic string, listener func(topic string, msg []byte, time time.Time) error, errorhandler func(err error, consumer *Consumer)) (consumer *Consumer, err error) {
-	consumer = &Consumer{groupId: groupid, zkUrl: zk, topic: topic, listener: listener, errorhandler: errorhandler}
+	return NewConsumerWithContext(context.Background(), zk, groupid, topic, listener, errorhandler)
+}
+
+//the consumer stops fetching new messages when ctx is done
+func NewConsumerWithContext(ctx context.Context, zk string, groupid string, topic string, listener func(topic string, msg []byte, time time.Time) error, errorhandler func(err error, consumer *Consumer)) (consumer *Consumer, err error) {
+	return NewConsumerWithOptions(ctx, Cluster{ZookeeperUrl: zk}, groupid, topic, ConsumerOptions{}, listener, errorhandler)
+}
+
+func NewConsumerWithOptions(ctx context.Context, cluster Cluster, groupid string, topic string, options ConsumerOptions, listener func(topic string, msg []byte, time time.Time) error, errorhandler func(err error, consumer *Consumer)) (consumer *Consumer, err error) {
+	consumer = &Consumer{parentCtx: ctx, groupId: groupid, cluster: cluster, topic: topic, options: options, listener: listener, errorhandler: errorhandler}
 	err = consumer.start()
 	return
 }
 
+type ConsumerOptions struct {
+	//number of concurrent listener calls; messages with the same key (e.g. device id) are handled in order.
+	//a slow listener call only delays messages with the same key. values < 1 are handled as 1
+	Workers int
+
+	//listener calls per message before the message is moved to the dead letter topic
+	Retry RetryPolicy
+
+	//if DeadLetterTopic and DeadLetterProducer are set, messages which could not be handled are produced as DeadLetter to this topic and committed.
+	//without dead letter topic, failed messages are not committed but a commit of a following message will move the offset past them
+	DeadLetterTopic    string
+	DeadLetterProducer ProducerInterface
+
+	//floodgate: messages older than MaxMessageAge are committed without calling the listener.
+	//0 uses DefaultMaxMessageAge; negative values disable the floodgate
+	MaxMessageAge time.Duration
+
+	//optional; returns the time which is used to compute the message age. the kafka message timestamp is used if nil
+	MessageTime func(msg []byte, kafkaTime time.Time) time.Time
+
+	//optional; called for every message which is dropped by the floodgate
+	ExpiredHandler func(topic string, msg []byte, messageTime time.Time, age time.Duration)
+
+	//if true, expired messages are produced to the dead letter topic
+	ExpiredToDeadLetter bool
+}
+
+const DefaultMaxMessageAge = 1 * time.Hour
+
 type Consumer struct {
 	count        int
-	zkUrl        string
+	cluster      Cluster
 	groupId      string
 	topic        string
+	options      ConsumerOptions
+	parentCtx    context.Context
 	ctx          context.Context
 	cancel       context.CancelFunc
+	done         chan struct{}
 	listener     func(topic string, msg []byte, time time.Time) error
 	errorhandler func(err error, consumer *Consumer)
 	mux          sync.Mutex
 }
 
+//stops fetching new messages; messages which are currently handled by the listener will still be committed
 func (this *Consumer) Stop() {
-	this.cancel()
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.cancel != nil {
+		this.cancel()
+	}
+}
+
+//returns a channel which is closed when the consumer has stopped, all running listener calls are finished and its kafka reader is closed
+func (this *Consumer) Done() <-chan struct{} {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.done
 }
 
 func (this *Consumer) start() error {
 	log.Println("DEBUG: consume topic: \"" + this.topic + "\"")
-	this.ctx, this.cancel = context.WithCancel(context.Background())
-	broker, err := GetBroker(this.zkUrl)
+	if err := this.parentCtx.Err(); err != nil {
+		return err
+	}
+	this.mux.Lock()
+	ctx, cancel := context.WithCancel(this.parentCtx)
+	done := make(chan struct{})
+	this.ctx, this.cancel, this.done = ctx, cancel, done
+	this.mux.Unlock()
+	broker, err := this.cluster.GetBroker()
 	if err != nil {
 		log.Println("ERROR: unable to get broker list", err)
+		close(done)
 		return err
 	}
-	err = InitTopic(this.zkUrl, this.topic)
+	err = this.cluster.InitTopic(this.topic)
 	if err != nil {
 		log.Println("ERROR: unable to create topic", err)
+		close(done)
+		return err
+	}
+	dialer, err := this.cluster.dialer()
+	if err != nil {
+		log.Println("ERROR: unable to create kafka dialer", err)
+		close(done)
 		return err
 	}
 	r := kafka.NewReader(kafka.ReaderConfig{
 		CommitInterval: 0, //synchronous commits
+		Dialer:         dialer,
 		Brokers:        broker,
 		GroupID:        this.groupId,
 		Topic:          this.topic,
@@ -70,50 +139,169 @@ func (this *Consumer) start() error {
 		Logger:         log.New(ioutil.Discard, "", 0),
 		ErrorLogger:    log.New(ioutil.Discard, "", 0),
 	})
+
+	//the error handler is called at most once per reader, even if multiple workers fail
+	errorOnce := sync.Once{}
+	handleError := func(err error) {
+		errorOnce.Do(func() {
+			this.errorhandler(err, this)
+		})
+	}
+
+	//commit even if ctx is canceled while the listener was running, to prevent duplicate handling after restart
+	tracker := newOffsetTracker(func(m kafka.Message) error {
+		return r.CommitMessages(context.Background(), m)
+	})
+	finish := func(m kafka.Message, success bool) {
+		err := tracker.finish(m, success)
+		if err != nil {
+			log.Println("ERROR: while committing message ", this.topic, err)
+			cancel()
+			handleError(err)
+		}
+	}
+
+	workers := newDispatcher(this.options.Workers, func(m kafka.Message) {
+		success, interrupted := this.handle(ctx, m)
+		if interrupted {
+			//leave message unfinished to prevent commits past it
+			return
+		}
+		finish(m, success)
+	})
+
 	go func() {
+		defer close(done)
+		defer r.Close()
+		defer workers.close()
 		for {
 			select {
-			case <-this.ctx.Done():
+			case <-ctx.Done():
 				log.Println("close kafka reader ", this.topic)
 				return
 			default:
-				m, err := r.FetchMessage(this.ctx)
-				if err == io.EOF || err == context.Canceled {
+				m, err := r.FetchMessage(ctx)
+				if err == io.EOF || err == context.Canceled || ctx.Err() != nil {
 					log.Println("close consumer for topic ", this.topic)
 					return
 				}
 				if err != nil {
 					log.Println("ERROR: while consuming topic ", this.topic, err)
-					this.errorhandler(err, this)
+					handleError(err)
 					return
 				}
-				if time.Now().Sub(m.Time) > 1*time.Hour { //floodgate to prevent old messages to DOS the consumer
-					log.Println("ERROR: kafka message older than 1h: ", this.topic, time.Now().Sub(m.Time))
-					err = r.CommitMessages(this.ctx, m)
-					if err != nil {
-						log.Println("ERROR: while committing message ", this.topic, err)
-						this.errorhandler(err, this)
-						return
-					}
-				} else {
-					err = this.listener(m.Topic, m.Value, m.Time)
-					if err != nil {
-						log.Println("ERROR: unable to handle message (no commit)", err)
-					} else {
-						err = r.CommitMessages(this.ctx, m)
-						if err != nil {
-							log.Println("ERROR: while committing message ", this.topic, err)
-							this.errorhandler(err, this)
-							return
-						}
-					}
+				tracker.add(m)
+				//floodgate before dispatch, so expired messages do not wait behind slow listener calls
+				if this.handleExpired(m) {
+					finish(m, true)
+					continue
 				}
+				workers.dispatch(m)
 			}
 		}
 	}()
 	return err
 }
 
+//calls the listener as defined by the retry policy and moves failed messages to the dead letter topic.
+//interrupted is true, if ctx is done while waiting for the next attempt
+func (this *Consumer) handle(ctx context.Context, m kafka.Message) (success bool, interrupted bool) {
+	attempts := this.options.Retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	firstAttempt := time.Now()
+	lastAttempt := firstAttempt
+	var err error
+	attempt := 1
+	for ; attempt <= attempts; attempt++ {
+		lastAttempt = time.Now()
+		err = this.listener(m.Topic, m.Value, m.Time)
+		if err == nil {
+			return true, false
+		}
+		if ctx.Err() != nil {
+			//e.g. the listener rejected the message because of a shutdown; handle it again after restart
+			return false, true
+		}
+		if IsNonRetryable(err) || attempt == attempts {
+			break
+		}
+		log.Println("WARNING: unable to handle message; retry", attempt, err)
+		select {
+		case <-ctx.Done():
+			return false, true
+		case <-time.After(this.options.Retry.Delay(attempt)):
+		}
+	}
+	if this.options.DeadLetterTopic == "" || this.options.DeadLetterProducer == nil {
+		log.Println("ERROR: unable to handle message (no commit)", err)
+		return false, false
+	}
+	log.Println("ERROR: unable to handle message; move to dead letter topic", this.options.DeadLetterTopic, err)
+	dlErr := this.sendDeadLetter(DeadLetter{
+		Topic:        m.Topic,
+		Partition:    m.Partition,
+		Offset:       m.Offset,
+		Key:          string(m.Key),
+		Payload:      string(m.Value),
+		Error:        err.Error(),
+		Attempts:     attempt,
+		MessageTime:  m.Time,
+		FirstAttempt: firstAttempt,
+		LastAttempt:  lastAttempt,
+		Replays:      replays(m),
+	})
+	if dlErr != nil {
+		log.Println("ERROR: unable to produce dead letter (no commit)", dlErr)
+		return false, false
+	}
+	return true, false
+}
+
+//returns true if the message is older than the configured max age
+func (this *Consumer) handleExpired(m kafka.Message) (expired bool) {
+	maxAge := this.options.MaxMessageAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxMessageAge
+	}
+	if maxAge < 0 {
+		return false
+	}
+	messageTime := m.Time
+	if this.options.MessageTime != nil {
+		messageTime = this.options.MessageTime(m.Value, m.Time)
+	}
+	age := time.Now().Sub(messageTime)
+	if age <= maxAge {
+		return false
+	}
+	log.Println("ERROR: kafka message older than", maxAge, ": ", this.topic, age)
+	if this.options.ExpiredHandler != nil {
+		this.options.ExpiredHandler(m.Topic, m.Value, messageTime, age)
+	}
+	if this.options.ExpiredToDeadLetter && this.options.DeadLetterTopic != "" && this.options.DeadLetterProducer != nil {
+		now := time.Now()
+		err := this.sendDeadLetter(DeadLetter{
+			Topic:        m.Topic,
+			Partition:    m.Partition,
+			Offset:       m.Offset,
+			Key:          string(m.Key),
+			Payload:      string(m.Value),
+			Error:        "message expired after " + age.String(),
+			Attempts:     0,
+			MessageTime:  messageTime,
+			FirstAttempt: now,
+			LastAttempt:  now,
+			Replays:      replays(m),
+		})
+		if err != nil {
+			log.Println("ERROR: unable to produce dead letter for expired message", err)
+		}
+	}
+	return true
+}
+
 func (this *Consumer) Restart() {
 	this.Stop()
 	this.start()
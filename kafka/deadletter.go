@@ -0,0 +1,169 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"strconv"
+	"time"
+)
+
+type RetryPolicy struct {
+	Attempts   int           //number of listener calls per message; values < 1 are handled as 1
+	Backoff    time.Duration //wait duration before the second attempt; doubled for every following attempt
+	MaxBackoff time.Duration //upper limit of the wait duration between attempts; 0 means no limit
+}
+
+//returns the wait duration after the given (failed) attempt
+func (this RetryPolicy) Delay(attempt int) (result time.Duration) {
+	result = this.Backoff
+	for i := 1; i < attempt && (this.MaxBackoff <= 0 || result < this.MaxBackoff); i++ {
+		result = result * 2
+	}
+	if this.MaxBackoff > 0 && result > this.MaxBackoff {
+		result = this.MaxBackoff
+	}
+	return result
+}
+
+type DeadLetter struct {
+	Topic        string    `json:"topic"`
+	Partition    int       `json:"partition"`
+	Offset       int64     `json:"offset"`
+	Key          string    `json:"key"`
+	Payload      string    `json:"payload"`
+	Error        string    `json:"error"`
+	Attempts     int       `json:"attempts"`
+	MessageTime  time.Time `json:"message_time"`
+	FirstAttempt time.Time `json:"first_attempt"`
+	LastAttempt  time.Time `json:"last_attempt"`
+	Replays      int       `json:"replays"` //number of times the payload has already been replayed by ReplayDeadLetters()
+}
+
+//kafka header of replayed messages; contains the number of replays
+const ReplayHeader = "dead_letter_replays"
+
+const DefaultMaxReplays = 3
+
+type ReplayOptions struct {
+	//if empty, the topic from which the message was moved to the dead letter topic is used
+	TargetTopic string
+
+	//dead letters which have already been replayed MaxReplays times are not replayed again. 0 uses DefaultMaxReplays
+	MaxReplays int
+
+	//optional; dead letters which exceed MaxReplays or can not be parsed are produced to this topic. they are only logged if empty
+	ParkingTopic string
+}
+
+//returns the number of replays from the ReplayHeader of m
+func replays(m kafka.Message) int {
+	for _, header := range m.Headers {
+		if header.Key == ReplayHeader {
+			count, _ := strconv.Atoi(string(header.Value))
+			return count
+		}
+	}
+	return 0
+}
+
+type nonRetryableError struct {
+	err error
+}
+
+func (this nonRetryableError) Error() string {
+	return this.err.Error()
+}
+
+func (this nonRetryableError) Unwrap() error {
+	return this.err
+}
+
+//listeners may wrap errors with NonRetryable() to move the message directly to the dead letter topic, without further attempts
+//(e.g. if the message can not be parsed)
+func NonRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return nonRetryableError{err: err}
+}
+
+func IsNonRetryable(err error) bool {
+	return errors.As(err, &nonRetryableError{})
+}
+
+func (this *Consumer) sendDeadLetter(letter DeadLetter) error {
+	msg, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	return this.options.DeadLetterProducer.ProduceWithKey(this.options.DeadLetterTopic, string(msg), letter.Key)
+}
+
+//consumes the dead letter topic and produces the original payloads with their original key to targetTopic.
+//if targetTopic is empty, the topic from which the message was moved to the dead letter topic is used.
+//the returned consumer runs until ctx is done or Stop() is called
+func ReplayDeadLetters(ctx context.Context, cluster Cluster, groupid string, deadLetterTopic string, targetTopic string, producer ProducerInterface, errorhandler func(err error, consumer *Consumer)) (consumer *Consumer, err error) {
+	return ReplayDeadLettersWithOptions(ctx, cluster, groupid, deadLetterTopic, ReplayOptions{TargetTopic: targetTopic}, producer, errorhandler)
+}
+
+//like ReplayDeadLetters(); the replay count is only tracked if producer implements MessageProducer
+func ReplayDeadLettersWithOptions(ctx context.Context, cluster Cluster, groupid string, deadLetterTopic string, options ReplayOptions, producer ProducerInterface, errorhandler func(err error, consumer *Consumer)) (consumer *Consumer, err error) {
+	return NewConsumerWithOptions(ctx, cluster, groupid, deadLetterTopic, ConsumerOptions{}, replayListener(options, producer), errorhandler)
+}
+
+func replayListener(options ReplayOptions, producer ProducerInterface) func(topic string, msg []byte, t time.Time) error {
+	maxReplays := options.MaxReplays
+	if maxReplays == 0 {
+		maxReplays = DefaultMaxReplays
+	}
+	park := func(msg []byte, reason string) error {
+		if options.ParkingTopic == "" {
+			log.Println("ERROR: drop dead letter;", reason, string(msg))
+			return nil
+		}
+		log.Println("WARNING: move dead letter to", options.ParkingTopic, ";", reason)
+		return producer.Produce(options.ParkingTopic, string(msg))
+	}
+	return func(topic string, msg []byte, t time.Time) error {
+		letter := DeadLetter{}
+		err := json.Unmarshal(msg, &letter)
+		if err != nil {
+			return park(msg, "unable to parse dead letter: "+err.Error())
+		}
+		if letter.Replays >= maxReplays {
+			return park(msg, "dead letter has already been replayed "+strconv.Itoa(letter.Replays)+" times")
+		}
+		target := options.TargetTopic
+		if target == "" {
+			target = letter.Topic
+		}
+		if messageProducer, ok := producer.(MessageProducer); ok {
+			return messageProducer.ProduceMessage(Message{
+				Topic:   target,
+				Key:     letter.Key,
+				Value:   letter.Payload,
+				Headers: map[string]string{ReplayHeader: strconv.Itoa(letter.Replays + 1)},
+			})
+		}
+		return producer.ProduceWithKey(target, letter.Payload, letter.Key)
+	}
+}
@@ -0,0 +1,226 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"testing"
+	"time"
+)
+
+type producerMock struct {
+	topics   []string
+	messages []string
+	keys     []string
+}
+
+func (this *producerMock) Produce(topic string, message string) (err error) {
+	return this.ProduceWithKey(topic, message, "")
+}
+
+func (this *producerMock) ProduceWithKey(topic string, message string, key string) (err error) {
+	this.topics = append(this.topics, topic)
+	this.messages = append(this.messages, message)
+	this.keys = append(this.keys, key)
+	return nil
+}
+
+func (this *producerMock) Log(logger *log.Logger) {}
+
+func (this *producerMock) Close() {}
+
+func TestConsumerRetryAndDeadLetter(t *testing.T) {
+	producer := &producerMock{}
+	calls := 0
+	consumer := &Consumer{
+		options: ConsumerOptions{
+			Retry:              RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+			DeadLetterTopic:    "dead",
+			DeadLetterProducer: producer,
+		},
+		listener: func(topic string, msg []byte, time time.Time) error {
+			calls++
+			return errors.New("test error")
+		},
+	}
+	success, interrupted := consumer.handle(context.Background(), kafka.Message{Topic: "protocol", Key: []byte("device"), Value: []byte("payload"), Offset: 42, Time: time.Now()})
+	if !success || interrupted {
+		t.Fatal(success, interrupted)
+	}
+	if calls != 3 {
+		t.Fatal(calls)
+	}
+	if len(producer.messages) != 1 || producer.topics[0] != "dead" || producer.keys[0] != "device" {
+		t.Fatal(producer.topics, producer.keys)
+	}
+	letter := DeadLetter{}
+	err := json.Unmarshal([]byte(producer.messages[0]), &letter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if letter.Topic != "protocol" || letter.Payload != "payload" || letter.Offset != 42 || letter.Attempts != 3 || letter.Error != "test error" {
+		t.Fatal(letter)
+	}
+}
+
+func TestConsumerNonRetryable(t *testing.T) {
+	producer := &producerMock{}
+	calls := 0
+	consumer := &Consumer{
+		options: ConsumerOptions{
+			Retry:              RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+			DeadLetterTopic:    "dead",
+			DeadLetterProducer: producer,
+		},
+		listener: func(topic string, msg []byte, time time.Time) error {
+			calls++
+			return NonRetryable(errors.New("test error"))
+		},
+	}
+	success, _ := consumer.handle(context.Background(), kafka.Message{Topic: "protocol", Value: []byte("payload"), Time: time.Now()})
+	if !success || calls != 1 || len(producer.messages) != 1 {
+		t.Fatal(success, calls, len(producer.messages))
+	}
+}
+
+func TestConsumerRetryInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer := &Consumer{
+		options: ConsumerOptions{
+			Retry: RetryPolicy{Attempts: 3, Backoff: time.Hour},
+		},
+		listener: func(topic string, msg []byte, time time.Time) error {
+			cancel()
+			return errors.New("test error")
+		},
+	}
+	success, interrupted := consumer.handle(ctx, kafka.Message{Time: time.Now()})
+	if success || !interrupted {
+		t.Fatal(success, interrupted)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if actual := policy.Delay(i + 1); actual != e {
+			t.Error(i+1, actual, e)
+		}
+	}
+}
+
+func TestConsumerExpiredMessage(t *testing.T) {
+	producer := &producerMock{}
+	calls := 0
+	expired := 0
+	consumer := &Consumer{
+		options: ConsumerOptions{
+			DeadLetterTopic:     "dead",
+			DeadLetterProducer:  producer,
+			MaxMessageAge:       30 * time.Second,
+			ExpiredToDeadLetter: true,
+			MessageTime: func(msg []byte, kafkaTime time.Time) time.Time {
+				if string(msg) == "old" {
+					return time.Now().Add(-time.Minute)
+				}
+				return kafkaTime
+			},
+			ExpiredHandler: func(topic string, msg []byte, messageTime time.Time, age time.Duration) {
+				expired++
+			},
+		},
+		listener: func(topic string, msg []byte, time time.Time) error {
+			calls++
+			return nil
+		},
+	}
+	if !consumer.handleExpired(kafka.Message{Value: []byte("old"), Time: time.Now()}) || expired != 1 || len(producer.messages) != 1 {
+		t.Fatal(expired, len(producer.messages))
+	}
+	if consumer.handleExpired(kafka.Message{Value: []byte("new"), Time: time.Now()}) || expired != 1 || len(producer.messages) != 1 {
+		t.Fatal(expired, len(producer.messages))
+	}
+	if !consumer.handleExpired(kafka.Message{Value: []byte("new"), Time: time.Now().Add(-time.Minute)}) || expired != 2 {
+		t.Fatal(expired)
+	}
+	if calls != 0 {
+		t.Fatal(calls)
+	}
+}
+
+type messageProducerMock struct {
+	producerMock
+	headers []map[string]string
+}
+
+func (this *messageProducerMock) ProduceMessage(message Message) (err error) {
+	this.headers = append(this.headers, message.Headers)
+	return this.ProduceWithKey(message.Topic, message.Value, message.Key)
+}
+
+func (this *messageProducerMock) ProduceBatch(messages []Message) (errs []error) {
+	for _, message := range messages {
+		errs = append(errs, this.ProduceMessage(message))
+	}
+	return errs
+}
+
+func TestConsumerDeadLetterReplays(t *testing.T) {
+	producer := &producerMock{}
+	consumer := &Consumer{
+		options: ConsumerOptions{
+			DeadLetterTopic:    "dead",
+			DeadLetterProducer: producer,
+		},
+		listener: func(topic string, msg []byte, time time.Time) error {
+			return errors.New("test error")
+		},
+	}
+	consumer.handle(context.Background(), kafka.Message{Topic: "protocol", Value: []byte("payload"), Time: time.Now(), Headers: []kafka.Header{{Key: ReplayHeader, Value: []byte("2")}}})
+	letter := DeadLetter{}
+	err := json.Unmarshal([]byte(producer.messages[0]), &letter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if letter.Replays != 2 {
+		t.Fatal(letter)
+	}
+}
+
+func TestReplayListener(t *testing.T) {
+	producer := &messageProducerMock{}
+	listener := replayListener(ReplayOptions{MaxReplays: 2, ParkingTopic: "parking"}, producer)
+	letter, _ := json.Marshal(DeadLetter{Topic: "protocol", Key: "device", Payload: "payload", Replays: 1})
+	exhausted, _ := json.Marshal(DeadLetter{Topic: "protocol", Key: "device", Payload: "payload", Replays: 2})
+	for _, msg := range [][]byte{letter, exhausted, []byte("invalid")} {
+		if err := listener("dead", msg, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(producer.topics) != 3 || producer.topics[0] != "protocol" || producer.topics[1] != "parking" || producer.topics[2] != "parking" {
+		t.Fatal(producer.topics)
+	}
+	if producer.messages[0] != "payload" || producer.keys[0] != "device" || producer.headers[0][ReplayHeader] != "2" {
+		t.Fatal(producer.messages[0], producer.keys[0], producer.headers[0])
+	}
+	if producer.messages[2] != "invalid" {
+		t.Fatal(producer.messages[2])
+	}
+}
+
+func TestReplayListenerWithoutParkingTopic(t *testing.T) {
+	producer := &messageProducerMock{}
+	listener := replayListener(ReplayOptions{}, producer)
+	exhausted, _ := json.Marshal(DeadLetter{Topic: "protocol", Payload: "payload", Replays: DefaultMaxReplays})
+	for _, msg := range [][]byte{exhausted, []byte("invalid")} {
+		if err := listener("dead", msg, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(producer.messages) != 0 {
+		t.Fatal(producer.messages)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+type DeliveryReport struct {
+	Topic     string
+	Key       string
+	Message   string
+	Timestamp time.Time //kafka record timestamp
+	Err       error     //nil if the message is persisted by kafka
+	Attempts  int
+}
+
+//implemented by SyncProducer and AsyncProducer
+type ReportingProducer interface {
+	ProducerInterface
+	//report is called exactly once, when the message is persisted or finally failed
+	ProduceWithReport(message Message, report func(report DeliveryReport)) (err error)
+	//report is called exactly once per message which has been given to kafka
+	ProduceBatchWithReport(messages []Message, report func(report DeliveryReport)) (errs []error)
+	Statistics() ProducerStatistics
+}
+
+type ProducerOptions struct {
+	//async only: number of times a failed message is enqueued again
+	Retries int
+	//async only: wait duration before a failed message is enqueued again
+	RetryBackoff time.Duration
+	//called for every finally failed message; errors are logged if nil
+	ErrorHandler func(report DeliveryReport)
+}
+
+type ProducerStatistics struct {
+	Produced  uint64 //messages given to the producer
+	Persisted uint64 //messages acknowledged by kafka
+	Retried   uint64 //failed messages which were enqueued again
+	Dropped   uint64 //finally failed messages
+}
+
+type producerCounter struct {
+	produced  uint64
+	persisted uint64
+	retried   uint64
+	dropped   uint64
+}
+
+func (this *producerCounter) statistics() ProducerStatistics {
+	return ProducerStatistics{
+		Produced:  atomic.LoadUint64(&this.produced),
+		Persisted: atomic.LoadUint64(&this.persisted),
+		Retried:   atomic.LoadUint64(&this.retried),
+		Dropped:   atomic.LoadUint64(&this.dropped),
+	}
+}
+
+//stored in sarama.ProducerMessage.Metadata to track retries and the delivery report callback
+type deliveryMetadata struct {
+	message  string
+	attempts int
+	report   func(report DeliveryReport)
+}
+
+func (this *AsyncProducer) handleDeliveries() {
+	this.deliveries.Add(2)
+	go func() {
+		defer this.deliveries.Done()
+		for msg := range this.producer.Successes() {
+			atomic.AddUint64(&this.counter.persisted, 1)
+			this.reportDelivery(msg, nil)
+			this.pending.Done()
+		}
+	}()
+	go func() {
+		defer this.deliveries.Done()
+		for producerErr := range this.producer.Errors() {
+			log.Println("ERROR: kafka async producer", producerErr.Err)
+			msg := producerErr.Msg
+			metadata, _ := msg.Metadata.(*deliveryMetadata)
+			if metadata != nil && metadata.attempts <= this.options.Retries {
+				atomic.AddUint64(&this.counter.retried, 1)
+				this.deliveries.Add(1)
+				go this.retry(msg, producerErr.Err)
+				continue
+			}
+			this.drop(msg, producerErr.Err)
+		}
+	}()
+}
+
+//messages are dropped if the sarama producer has been closed by CloseWithContext() in the meantime
+func (this *AsyncProducer) retry(msg *sarama.ProducerMessage, err error) {
+	defer this.deliveries.Done()
+	time.Sleep(this.options.RetryBackoff)
+	if metadata, ok := msg.Metadata.(*deliveryMetadata); ok {
+		metadata.attempts++
+	}
+	this.inputMux.RLock()
+	defer this.inputMux.RUnlock()
+	if this.inputClosed {
+		this.drop(msg, err)
+		return
+	}
+	this.producer.Input() <- msg
+}
+
+func (this *AsyncProducer) drop(msg *sarama.ProducerMessage, err error) {
+	atomic.AddUint64(&this.counter.dropped, 1)
+	report := this.reportDelivery(msg, err)
+	if this.options.ErrorHandler != nil {
+		this.options.ErrorHandler(report)
+	}
+	this.pending.Done()
+}
+
+func (this *AsyncProducer) reportDelivery(msg *sarama.ProducerMessage, err error) (report DeliveryReport) {
+	report = DeliveryReport{Topic: msg.Topic, Timestamp: msg.Timestamp, Err: err}
+	if msg.Key != nil {
+		key, _ := msg.Key.Encode()
+		report.Key = string(key)
+	}
+	if metadata, ok := msg.Metadata.(*deliveryMetadata); ok {
+		report.Message = metadata.message
+		report.Attempts = metadata.attempts
+		if metadata.report != nil {
+			metadata.report(report)
+		}
+	}
+	return report
+}
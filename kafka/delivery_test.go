@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncProducerDeliveryReports(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	mock := mocks.NewAsyncProducer(t, config)
+	mock.ExpectInputAndSucceed()
+	mock.ExpectInputAndFail(errors.New("test error 1"))
+	mock.ExpectInputAndFail(errors.New("test error 2"))
+
+	mux := sync.Mutex{}
+	reports := []DeliveryReport{}
+	failed := []DeliveryReport{}
+	producer := &AsyncProducer{
+		producer:   mock,
+		usedTopics: map[string]bool{"test": true},
+		options: ProducerOptions{
+			Retries: 1,
+			ErrorHandler: func(report DeliveryReport) {
+				mux.Lock()
+				defer mux.Unlock()
+				failed = append(failed, report)
+			},
+		},
+	}
+	producer.handleDeliveries()
+
+	report := func(report DeliveryReport) {
+		mux.Lock()
+		defer mux.Unlock()
+		reports = append(reports, report)
+	}
+	eventTime := time.Now().Add(-2 * time.Hour).Truncate(time.Millisecond)
+	err := producer.ProduceWithReport(Message{Topic: "test", Value: "msg1", Key: "key1", Timestamp: eventTime}, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = producer.ProduceWithReport(Message{Topic: "test", Value: "msg2", Key: "key2"}, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer.Close()
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Message < reports[j].Message
+	})
+	if len(reports) != 2 || reports[0].Err != nil || reports[0].Message != "msg1" || reports[0].Key != "key1" || !reports[0].Timestamp.Equal(eventTime) {
+		t.Fatal(reports)
+	}
+	if reports[1].Err == nil || reports[1].Message != "msg2" || reports[1].Attempts != 2 {
+		t.Fatal(reports[1])
+	}
+	if len(failed) != 1 || failed[0].Message != "msg2" {
+		t.Fatal(failed)
+	}
+	statistics := producer.Statistics()
+	if statistics != (ProducerStatistics{Produced: 2, Persisted: 1, Retried: 1, Dropped: 1}) {
+		t.Fatal(statistics)
+	}
+	if producer.Produce("test", "msg3") != ErrProducerClosed {
+		t.Fatal("expected closed producer")
+	}
+}
+
+func TestAsyncProducerCloseWithContext(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	mock := mocks.NewAsyncProducer(t, config)
+	mock.ExpectInputAndFail(errors.New("test error"))
+
+	dropped := make(chan DeliveryReport, 1)
+	producer := &AsyncProducer{
+		producer:   mock,
+		usedTopics: map[string]bool{"test": true},
+		options: ProducerOptions{
+			Retries:      10,
+			RetryBackoff: 200 * time.Millisecond,
+			ErrorHandler: func(report DeliveryReport) {
+				dropped <- report
+			},
+		},
+	}
+	producer.handleDeliveries()
+	err := producer.Produce("test", "msg1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = producer.CloseWithContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	select {
+	case report := <-dropped:
+		if report.Message != "msg1" || report.Attempts != 2 {
+			t.Fatal(report)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending message was not dropped after close")
+	}
+}
+
+func TestSyncProducerBatch(t *testing.T) {
+	mock := mocks.NewSyncProducer(t, sarama.NewConfig())
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndFail(errors.New("test error"))
+	producer := &SyncProducer{producer: mock, usedTopics: map[string]bool{"a": true, "b": true}}
+	defer producer.Close()
+
+	errs := producer.ProduceBatch([]Message{{Topic: "a", Key: "1", Value: "v1"}, {Topic: "b", Key: "2", Value: "v2"}})
+	if len(errs) != 2 || errs[0] != nil || errs[1] != nil {
+		t.Fatal(errs)
+	}
+	errs = producer.ProduceBatch([]Message{{Topic: "a", Key: "1", Value: "v3"}, {Topic: "b", Key: "2", Value: "v4"}})
+	if len(errs) != 2 || errs[0] == nil || errs[1] == nil {
+		t.Fatal(errs)
+	}
+	statistics := producer.Statistics()
+	if statistics != (ProducerStatistics{Produced: 4, Persisted: 2, Dropped: 2}) {
+		t.Fatal(statistics)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"github.com/segmentio/kafka-go"
+	"sync"
+)
+
+//number of fetched messages per worker which may wait for their worker before dispatch blocks
+const maxPendingPerWorker = 100
+
+//distributes messages to a fixed number of workers; messages with the same key are queued per key and handled one after another
+//to preserve their order while messages with different keys may be handled in parallel.
+//a slow message only delays following messages with the same key
+type dispatcher struct {
+	mux        sync.Mutex
+	cond       *sync.Cond
+	pending    map[string][]kafka.Message //queued and active messages per key; the first message of a key is handled by a worker
+	ready      []string                   //keys with pending messages which are not handled by a worker
+	count      int
+	maxPending int
+	closed     bool
+	wg         sync.WaitGroup
+}
+
+func newDispatcher(workers int, handler func(m kafka.Message)) *dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	result := &dispatcher{pending: map[string][]kafka.Message{}, maxPending: workers * maxPendingPerWorker}
+	result.cond = sync.NewCond(&result.mux)
+	for i := 0; i < workers; i++ {
+		result.wg.Add(1)
+		go func() {
+			defer result.wg.Done()
+			result.work(handler)
+		}()
+	}
+	return result
+}
+
+//blocks only while maxPending messages are waiting or handled
+func (this *dispatcher) dispatch(m kafka.Message) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for this.count >= this.maxPending && !this.closed {
+		this.cond.Wait()
+	}
+	if this.closed {
+		return
+	}
+	this.count++
+	key := string(m.Key)
+	queue, known := this.pending[key]
+	this.pending[key] = append(queue, m)
+	if !known {
+		this.ready = append(this.ready, key)
+		this.cond.Broadcast()
+	}
+}
+
+func (this *dispatcher) work(handler func(m kafka.Message)) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for {
+		for len(this.ready) == 0 && !this.closed {
+			this.cond.Wait()
+		}
+		if this.closed {
+			return
+		}
+		key := this.ready[0]
+		this.ready = this.ready[1:]
+		m := this.pending[key][0]
+		this.mux.Unlock()
+		handler(m)
+		this.mux.Lock()
+		this.count--
+		if queue := this.pending[key][1:]; len(queue) > 0 {
+			//requeue the key behind other waiting keys
+			this.pending[key] = queue
+			this.ready = append(this.ready, key)
+		} else {
+			delete(this.pending, key)
+		}
+		this.cond.Broadcast()
+	}
+}
+
+//stops the workers after they have handled their current message; pending messages are dropped and stay uncommitted
+func (this *dispatcher) close() {
+	this.mux.Lock()
+	this.closed = true
+	this.cond.Broadcast()
+	this.mux.Unlock()
+	this.wg.Wait()
+}
+
+//tracks fetched messages per partition and commits only offsets of which all previous messages are finished
+type offsetTracker struct {
+	mux     sync.Mutex
+	pending map[int][]*trackedMessage
+	commit  func(m kafka.Message) error
+}
+
+type trackedMessage struct {
+	msg      kafka.Message
+	finished bool
+	success  bool
+}
+
+func newOffsetTracker(commit func(m kafka.Message) error) *offsetTracker {
+	return &offsetTracker{pending: map[int][]*trackedMessage{}, commit: commit}
+}
+
+//must be called in fetch order before the message is handed to a worker
+func (this *offsetTracker) add(m kafka.Message) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.pending[m.Partition] = append(this.pending[m.Partition], &trackedMessage{msg: m})
+}
+
+//marks the message as finished and commits the newest successful message which is not preceded by unfinished messages.
+//failed messages are not committed themselves, but do not block commits of later messages
+func (this *offsetTracker) finish(m kafka.Message, success bool) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	queue := this.pending[m.Partition]
+	for _, tracked := range queue {
+		if tracked.msg.Offset == m.Offset {
+			tracked.finished = true
+			tracked.success = success
+			break
+		}
+	}
+	var toCommit *kafka.Message
+	i := 0
+	for ; i < len(queue) && queue[i].finished; i++ {
+		if queue[i].success {
+			toCommit = &queue[i].msg
+		}
+	}
+	this.pending[m.Partition] = queue[i:]
+	if toCommit != nil {
+		return this.commit(*toCommit)
+	}
+	return nil
+}
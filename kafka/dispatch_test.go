@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	committed := []int64{}
+	tracker := newOffsetTracker(func(m kafka.Message) error {
+		committed = append(committed, m.Offset)
+		return nil
+	})
+	msg := func(partition int, offset int64) kafka.Message {
+		return kafka.Message{Partition: partition, Offset: offset}
+	}
+	tracker.add(msg(0, 1))
+	tracker.add(msg(0, 2))
+	tracker.add(msg(0, 3))
+	tracker.add(msg(1, 1))
+	tracker.add(msg(0, 4))
+
+	tracker.finish(msg(0, 2), true)
+	if len(committed) != 0 {
+		t.Fatal("commit before previous message is finished", committed)
+	}
+	tracker.finish(msg(1, 1), true)
+	tracker.finish(msg(0, 1), true)
+	tracker.finish(msg(0, 4), false)
+	tracker.finish(msg(0, 3), false)
+
+	//failed messages are not committed but do not block previous successful messages
+	if !reflect.DeepEqual(committed, []int64{1, 2}) {
+		t.Fatal(committed)
+	}
+
+	tracker.add(msg(0, 5))
+	tracker.finish(msg(0, 5), true)
+	if !reflect.DeepEqual(committed, []int64{1, 2, 5}) {
+		t.Fatal(committed)
+	}
+}
+
+func TestDispatcherKeyOrder(t *testing.T) {
+	mux := sync.Mutex{}
+	result := map[string][]int64{}
+	handled := sync.WaitGroup{}
+	handled.Add(50)
+	workers := newDispatcher(4, func(m kafka.Message) {
+		defer handled.Done()
+		if m.Offset%3 == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		result[string(m.Key)] = append(result[string(m.Key)], m.Offset)
+	})
+	keys := []string{"a", "b", "c", "d", "e"}
+	for i := int64(0); i < 50; i++ {
+		workers.dispatch(kafka.Message{Key: []byte(keys[i%int64(len(keys))]), Offset: i})
+	}
+	handled.Wait()
+	workers.close()
+	for key, offsets := range result {
+		if len(offsets) != 10 {
+			t.Error(key, offsets)
+		}
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i-1] >= offsets[i] {
+				t.Error("unexpected order", key, offsets)
+			}
+		}
+	}
+}
+
+func TestDispatcherSlowKey(t *testing.T) {
+	block := make(chan struct{})
+	handled := make(chan int64, 100)
+	workers := newDispatcher(2, func(m kafka.Message) {
+		if string(m.Key) == "slow" {
+			<-block
+		}
+		handled <- m.Offset
+	})
+	//the slow key occupies one worker and has more queued messages
+	workers.dispatch(kafka.Message{Key: []byte("slow"), Offset: 0})
+	workers.dispatch(kafka.Message{Key: []byte("slow"), Offset: 1})
+	workers.dispatch(kafka.Message{Key: []byte("slow"), Offset: 2})
+	dispatched := make(chan bool)
+	go func() {
+		for i := int64(3); i < 23; i++ {
+			workers.dispatch(kafka.Message{Key: []byte(string(rune('a' + i%5))), Offset: i})
+		}
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch blocked by slow key")
+	}
+	for i := 0; i < 20; i++ {
+		select {
+		case offset := <-handled:
+			if offset < 3 {
+				t.Fatal("slow message handled before unblock", offset)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("messages of other keys are not handled while one key is blocked")
+		}
+	}
+	close(block)
+	for i := int64(0); i < 3; i++ {
+		if offset := <-handled; offset != i {
+			t.Fatal("unexpected order", offset)
+		}
+	}
+	workers.close()
+}
+
+func TestDispatcherMaxPending(t *testing.T) {
+	block := make(chan struct{})
+	workers := newDispatcher(1, func(m kafka.Message) {
+		<-block
+	})
+	dispatched := make(chan bool)
+	go func() {
+		for i := 0; i <= maxPendingPerWorker; i++ {
+			workers.dispatch(kafka.Message{Key: []byte("key"), Offset: int64(i)})
+		}
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+		t.Fatal("dispatch should block if maxPending messages are pending")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(block)
+	<-dispatched
+	workers.close()
+}
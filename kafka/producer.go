@@ -17,15 +17,19 @@
 package kafka
 
 import (
+	"context"
 	"errors"
 	"github.com/Shopify/sarama"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var Fatal = false
 
+var ErrProducerClosed = errors.New("kafka producer is closed")
+
 type ProducerInterface interface {
 	Produce(topic string, message string) (err error)
 	ProduceWithKey(topic string, message string, key string) (err error)
@@ -33,35 +37,127 @@ type ProducerInterface interface {
 	Close()
 }
 
+type Message struct {
+	Topic     string
+	Key       string
+	Value     string
+	Timestamp time.Time //kafka record timestamp; time.Now() is used if zero
+	Headers   map[string]string
+}
+
+func (this Message) timestamp() time.Time {
+	if this.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return this.Timestamp
+}
+
+func (this Message) headers() (result []sarama.RecordHeader) {
+	for key, value := range this.Headers {
+		result = append(result, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	return result
+}
+
+//implemented by SyncProducer and AsyncProducer
+type MessageProducer interface {
+	ProducerInterface
+	ProduceMessage(message Message) (err error)
+	ProduceBatch(messages []Message) (errs []error)
+}
+
 type SyncProducer struct {
 	broker         []string
 	logger         *log.Logger
 	producer       sarama.SyncProducer
-	zk             string
+	cluster        Cluster
 	syncIdempotent bool
 	mux            sync.Mutex
 	usedTopics     map[string]bool
+	closed         bool
+	options        ProducerOptions
+	counter        producerCounter
 }
 
+//waits for running sends and closes the producer; following produce calls return ErrProducerClosed
 func (this *SyncProducer) Close() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.closed {
+		return
+	}
+	this.closed = true
 	this.producer.Close()
 }
 
 type AsyncProducer struct {
-	broker     []string
-	logger     *log.Logger
-	producer   sarama.AsyncProducer
-	zk         string
-	usedTopics map[string]bool
+	broker      []string
+	logger      *log.Logger
+	producer    sarama.AsyncProducer
+	cluster     Cluster
+	usedTopics  map[string]bool
+	topicMux    sync.Mutex
+	mux         sync.RWMutex
+	closed      bool
+	options     ProducerOptions
+	counter     producerCounter
+	pending     sync.WaitGroup //messages without delivery report
+	deliveries  sync.WaitGroup //goroutines which handle delivery reports
+	inputMux    sync.RWMutex
+	inputClosed bool //set before the sarama producer is closed; retries are dropped afterwards
 }
 
+//waits until all produced messages are persisted or finally failed and closes the producer; following produce calls return ErrProducerClosed
 func (this *AsyncProducer) Close() {
-	this.producer.Close()
+	this.CloseWithContext(context.Background())
+}
+
+//like Close() but stops waiting when ctx is done; messages which are still pending are not retried anymore and ctx.Err() is returned
+func (this *AsyncProducer) CloseWithContext(ctx context.Context) error {
+	this.mux.Lock()
+	if this.closed {
+		this.mux.Unlock()
+		return nil
+	}
+	this.closed = true
+	this.mux.Unlock()
+	err := waitWithContext(ctx, &this.pending)
+	this.inputMux.Lock()
+	this.inputClosed = true
+	this.inputMux.Unlock()
+	this.producer.AsyncClose()
+	if err != nil {
+		return err
+	}
+	return waitWithContext(ctx, &this.deliveries)
+}
+
+//the goroutine which waits for wg ends when wg is done, even if ctx is done earlier
+func waitWithContext(ctx context.Context, wg *sync.WaitGroup) error {
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func PrepareProducer(zk string, sync bool, syncIdempotent bool) (ProducerInterface, error) {
+	return PrepareProducerWithCluster(Cluster{ZookeeperUrl: zk}, sync, syncIdempotent)
+}
+
+func PrepareProducerWithCluster(cluster Cluster, sync bool, syncIdempotent bool) (ProducerInterface, error) {
+	return PrepareProducerWithOptions(cluster, sync, syncIdempotent, ProducerOptions{})
+}
+
+func PrepareProducerWithOptions(cluster Cluster, sync bool, syncIdempotent bool, options ProducerOptions) (ReportingProducer, error) {
 	var err error
-	broker, err := GetBroker(zk)
+	broker, err := cluster.GetBroker()
 	if err != nil {
 		return nil, err
 	}
@@ -69,9 +165,11 @@ func PrepareProducer(zk string, sync bool, syncIdempotent bool) (ProducerInterfa
 		return nil, errors.New("missing kafka broker")
 	}
 	if sync {
-		result := &SyncProducer{broker: broker, zk: zk, syncIdempotent: syncIdempotent, usedTopics: map[string]bool{}}
-		sarama_conf := sarama.NewConfig()
-		sarama_conf.Version = sarama.V2_2_0_0
+		result := &SyncProducer{broker: broker, cluster: cluster, syncIdempotent: syncIdempotent, usedTopics: map[string]bool{}, options: options}
+		sarama_conf, err := cluster.saramaConfig()
+		if err != nil {
+			return nil, err
+		}
 		sarama_conf.Producer.Return.Errors = true
 		sarama_conf.Producer.Return.Successes = true
 		if syncIdempotent {
@@ -80,20 +178,23 @@ func PrepareProducer(zk string, sync bool, syncIdempotent bool) (ProducerInterfa
 			sarama_conf.Producer.RequiredAcks = sarama.WaitForAll
 		}
 		result.producer, err = sarama.NewSyncProducer(result.broker, sarama_conf)
+		if err != nil {
+			return nil, err
+		}
 		return result, err
 	} else {
-		result := &AsyncProducer{broker: broker, zk: zk, usedTopics: map[string]bool{}}
-		sarama_conf := sarama.NewConfig()
-		sarama_conf.Version = sarama.V2_2_0_0
+		result := &AsyncProducer{broker: broker, cluster: cluster, usedTopics: map[string]bool{}, options: options}
+		sarama_conf, err := cluster.saramaConfig()
+		if err != nil {
+			return nil, err
+		}
 		sarama_conf.Producer.Return.Errors = true
-		sarama_conf.Producer.Return.Successes = false
+		sarama_conf.Producer.Return.Successes = true
 		result.producer, err = sarama.NewAsyncProducer(result.broker, sarama_conf)
-		go func() {
-			err, ok := <-result.producer.Errors()
-			if ok {
-				log.Fatal(err)
-			}
-		}()
+		if err != nil {
+			return nil, err
+		}
+		result.handleDeliveries()
 		return result, err
 	}
 }
@@ -103,54 +204,180 @@ func (this *SyncProducer) Log(logger *log.Logger) {
 }
 
 func (this *SyncProducer) Produce(topic string, message string) (err error) {
+	return this.produce(topic, message, nil, time.Now(), nil, nil)
+}
+
+func (this *AsyncProducer) Produce(topic string, message string) (err error) {
+	return this.produce(topic, message, nil, time.Now(), nil, nil)
+}
+
+func (this *SyncProducer) ProduceWithKey(topic string, message string, key string) (err error) {
+	return this.produce(topic, message, sarama.StringEncoder(key), time.Now(), nil, nil)
+}
+
+func (this *AsyncProducer) ProduceWithKey(topic string, message string, key string) (err error) {
+	return this.produce(topic, message, sarama.StringEncoder(key), time.Now(), nil, nil)
+}
+
+func (this *SyncProducer) ProduceMessage(message Message) (err error) {
+	return this.produce(message.Topic, message.Value, sarama.StringEncoder(message.Key), message.timestamp(), message.headers(), nil)
+}
+
+func (this *AsyncProducer) ProduceMessage(message Message) (err error) {
+	return this.produce(message.Topic, message.Value, sarama.StringEncoder(message.Key), message.timestamp(), message.headers(), nil)
+}
+
+//report is called before ProduceWithReport returns
+func (this *SyncProducer) ProduceWithReport(message Message, report func(report DeliveryReport)) (err error) {
+	return this.produce(message.Topic, message.Value, sarama.StringEncoder(message.Key), message.timestamp(), message.headers(), report)
+}
+
+//report is called asynchronously, when kafka acknowledged the message or the message finally failed
+func (this *AsyncProducer) ProduceWithReport(message Message, report func(report DeliveryReport)) (err error) {
+	return this.produce(message.Topic, message.Value, sarama.StringEncoder(message.Key), message.timestamp(), message.headers(), report)
+}
+
+//sends all messages with one request; errs contains one entry per message
+func (this *SyncProducer) ProduceBatch(messages []Message) (errs []error) {
+	return this.ProduceBatchWithReport(messages, nil)
+}
+
+//like ProduceBatch(); report is called for every sent message before ProduceBatchWithReport returns
+func (this *SyncProducer) ProduceBatchWithReport(messages []Message, report func(report DeliveryReport)) (errs []error) {
+	errs = make([]error, len(messages))
 	this.mux.Lock()
 	defer this.mux.Unlock()
-	if this.logger != nil {
-		this.logger.Println("DEBUG: produce ", topic, message)
+	if this.closed {
+		for i := range errs {
+			errs[i] = ErrProducerClosed
+		}
+		return errs
 	}
-	err = EnsureTopic(topic, this.zk, &this.usedTopics)
-	if err != nil {
-		return err
+	batch := []*sarama.ProducerMessage{}
+	for i, message := range messages {
+		if this.logger != nil {
+			this.logger.Println("DEBUG: produce ", message.Topic, message.Value)
+		}
+		err := this.cluster.EnsureTopic(message.Topic, &this.usedTopics)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		batch = append(batch, &sarama.ProducerMessage{Topic: message.Topic, Key: sarama.StringEncoder(message.Key), Value: sarama.StringEncoder(message.Value), Timestamp: message.timestamp(), Headers: message.headers(), Metadata: i})
 	}
-	_, _, err = this.producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Key: nil, Value: sarama.StringEncoder(message), Timestamp: time.Now()})
-	return err
+	if len(batch) == 0 {
+		return errs
+	}
+	atomic.AddUint64(&this.counter.produced, uint64(len(batch)))
+	err := this.producer.SendMessages(batch)
+	if producerErrors, ok := err.(sarama.ProducerErrors); ok {
+		for _, producerErr := range producerErrors {
+			errs[producerErr.Msg.Metadata.(int)] = producerErr.Err
+		}
+	} else if err != nil {
+		for _, msg := range batch {
+			errs[msg.Metadata.(int)] = err
+		}
+	}
+	for _, msg := range batch {
+		i := msg.Metadata.(int)
+		deliveryReport := DeliveryReport{Topic: messages[i].Topic, Key: messages[i].Key, Message: messages[i].Value, Timestamp: msg.Timestamp, Err: errs[i], Attempts: 1}
+		if errs[i] != nil {
+			atomic.AddUint64(&this.counter.dropped, 1)
+			if this.options.ErrorHandler != nil {
+				this.options.ErrorHandler(deliveryReport)
+			}
+		} else {
+			atomic.AddUint64(&this.counter.persisted, 1)
+		}
+		if report != nil {
+			report(deliveryReport)
+		}
+	}
+	return errs
 }
 
-func (this *AsyncProducer) Produce(topic string, message string) (err error) {
-	if this.logger != nil {
-		this.logger.Println("DEBUG: produce ", topic, message)
-	}
-	err = EnsureTopic(topic, this.zk, &this.usedTopics)
-	if err != nil {
-		return err
+//enqueues all messages; errs contains one entry per message and only reports errors which occurred before the messages were enqueued
+func (this *AsyncProducer) ProduceBatch(messages []Message) (errs []error) {
+	return this.ProduceBatchWithReport(messages, nil)
+}
+
+//like ProduceBatch(); report is called asynchronously for every enqueued message, like by ProduceWithReport()
+func (this *AsyncProducer) ProduceBatchWithReport(messages []Message, report func(report DeliveryReport)) (errs []error) {
+	errs = make([]error, len(messages))
+	for i, message := range messages {
+		errs[i] = this.produce(message.Topic, message.Value, sarama.StringEncoder(message.Key), message.timestamp(), message.headers(), report)
 	}
-	this.producer.Input() <- &sarama.ProducerMessage{Topic: topic, Key: nil, Value: sarama.StringEncoder(message), Timestamp: time.Now()}
-	return
+	return errs
 }
 
-func (this *SyncProducer) ProduceWithKey(topic string, message string, key string) (err error) {
+func (this *SyncProducer) Statistics() ProducerStatistics {
+	return this.counter.statistics()
+}
+
+func (this *AsyncProducer) Statistics() ProducerStatistics {
+	return this.counter.statistics()
+}
+
+func (this *SyncProducer) produce(topic string, message string, key sarama.Encoder, timestamp time.Time, headers []sarama.RecordHeader, report func(report DeliveryReport)) (err error) {
 	this.mux.Lock()
 	defer this.mux.Unlock()
+	if this.closed {
+		return ErrProducerClosed
+	}
 	if this.logger != nil {
 		this.logger.Println("DEBUG: produce ", topic, message)
 	}
-	err = EnsureTopic(topic, this.zk, &this.usedTopics)
+	err = this.cluster.EnsureTopic(topic, &this.usedTopics)
 	if err != nil {
 		return err
 	}
-	_, _, err = this.producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Key: sarama.StringEncoder(key), Value: sarama.StringEncoder(message), Timestamp: time.Now()})
+	atomic.AddUint64(&this.counter.produced, 1)
+	_, _, err = this.producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Key: key, Value: sarama.StringEncoder(message), Timestamp: timestamp, Headers: headers})
+	deliveryReport := DeliveryReport{Topic: topic, Message: message, Timestamp: timestamp, Err: err, Attempts: 1}
+	if key != nil {
+		keyBytes, _ := key.Encode()
+		deliveryReport.Key = string(keyBytes)
+	}
+	if err != nil {
+		atomic.AddUint64(&this.counter.dropped, 1)
+		if this.options.ErrorHandler != nil {
+			this.options.ErrorHandler(deliveryReport)
+		}
+	} else {
+		atomic.AddUint64(&this.counter.persisted, 1)
+	}
+	if report != nil {
+		report(deliveryReport)
+	}
 	return err
 }
 
-func (this *AsyncProducer) ProduceWithKey(topic string, message string, key string) (err error) {
+func (this *AsyncProducer) produce(topic string, message string, key sarama.Encoder, timestamp time.Time, headers []sarama.RecordHeader, report func(report DeliveryReport)) (err error) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	if this.closed {
+		return ErrProducerClosed
+	}
 	if this.logger != nil {
 		this.logger.Println("DEBUG: produce ", topic, message)
 	}
-	err = EnsureTopic(topic, this.zk, &this.usedTopics)
+	this.topicMux.Lock()
+	err = this.cluster.EnsureTopic(topic, &this.usedTopics)
+	this.topicMux.Unlock()
 	if err != nil {
 		return err
 	}
-	this.producer.Input() <- &sarama.ProducerMessage{Topic: topic, Key: sarama.StringEncoder(key), Value: sarama.StringEncoder(message), Timestamp: time.Now()}
+	atomic.AddUint64(&this.counter.produced, 1)
+	this.pending.Add(1)
+	this.producer.Input() <- &sarama.ProducerMessage{
+		Topic:     topic,
+		Key:       key,
+		Value:     sarama.StringEncoder(message),
+		Timestamp: timestamp,
+		Headers:   headers,
+		Metadata:  &deliveryMetadata{message: message, attempts: 1, report: report},
+	}
 	return
 }
 
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+//reads all partitions of topic without consumer group and without commits, starting at the latest offset.
+//only messages published after the call are passed to listener; the topic is not created and partitions added later are not read.
+//read errors are logged and the read is retried until ctx is done
+func Tail(ctx context.Context, cluster Cluster, topic string, listener func(topic string, msg []byte, time time.Time)) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		//stop the readers of already started partitions
+		if err != nil {
+			cancel()
+		}
+	}()
+	broker, err := cluster.GetBroker()
+	if err != nil {
+		return err
+	}
+	if len(broker) == 0 {
+		return errors.New("missing kafka broker")
+	}
+	dialer, err := cluster.dialer()
+	if err != nil {
+		return err
+	}
+	partitions, err := dialer.LookupPartitions(ctx, "tcp", broker[0], topic)
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		return errors.New("unknown topic " + topic)
+	}
+	for _, partition := range partitions {
+		r := kafka.NewReader(kafka.ReaderConfig{
+			Dialer:      dialer,
+			Brokers:     broker,
+			Topic:       topic,
+			Partition:   partition.ID,
+			MaxWait:     1 * time.Second,
+			Logger:      log.New(ioutil.Discard, "", 0),
+			ErrorLogger: log.New(ioutil.Discard, "", 0),
+		})
+		err = r.SetOffset(kafka.LastOffset)
+		if err != nil {
+			r.Close()
+			return err
+		}
+		go func() {
+			defer r.Close()
+			for {
+				m, err := r.ReadMessage(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					log.Println("ERROR: while reading topic ", topic, err)
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(time.Second):
+					}
+					continue
+				}
+				listener(m.Topic, m.Value, m.Time)
+			}
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"fmt"
+	"github.com/Shopify/sarama"
+	"log"
+	"regexp"
+)
+
+//settings for topics which are created by this library
+type TopicPolicy struct {
+	Partitions        int               `json:"partitions"`         //values < 1 are handled as 1
+	ReplicationFactor int               `json:"replication_factor"` //values < 1 are handled as 1
+	Config            map[string]string `json:"config"`             //topic configs like retention.ms or cleanup.policy
+}
+
+type TopicPolicyOverride struct {
+	Pattern string      `json:"pattern"` //regular expression which has to match the topic name
+	Policy  TopicPolicy `json:"policy"`
+}
+
+type TopicPolicies struct {
+	Default   TopicPolicy
+	Overrides []TopicPolicyOverride //the first override with a matching pattern is used instead of Default
+
+	//called asynchronously by InitTopic() and EnsureTopic() for topics which do not satisfy their policy; violations are logged if nil
+	ViolationHandler func(violation TopicPolicyViolation)
+}
+
+type TopicPolicyViolation struct {
+	Topic                     string
+	Partitions                int
+	ExpectedPartitions        int
+	ReplicationFactor         int
+	ExpectedReplicationFactor int
+	Config                    map[string]string //current values of configs which differ from ExpectedConfig
+	ExpectedConfig            map[string]string
+}
+
+func (this TopicPolicyViolation) String() string {
+	return fmt.Sprintf("topic %v: partitions=%v (expected %v), replication-factor=%v (expected %v), config=%v (expected %v)",
+		this.Topic, this.Partitions, this.ExpectedPartitions, this.ReplicationFactor, this.ExpectedReplicationFactor, this.Config, this.ExpectedConfig)
+}
+
+//returns the policy for the topic with partitions and replication factor >= 1
+func (this TopicPolicies) Get(topic string) (result TopicPolicy) {
+	result = this.Default
+	for _, override := range this.Overrides {
+		matches, err := regexp.MatchString(override.Pattern, topic)
+		if err != nil {
+			log.Println("WARNING: invalid topic policy pattern", override.Pattern, err)
+			continue
+		}
+		if matches {
+			result = override.Policy
+			break
+		}
+	}
+	return result.normalized()
+}
+
+//partitions and replication factor < 1 are replaced by 1
+func (this TopicPolicy) normalized() TopicPolicy {
+	if this.Partitions < 1 {
+		this.Partitions = 1
+	}
+	if this.ReplicationFactor < 1 {
+		this.ReplicationFactor = 1
+	}
+	return this
+}
+
+func (this TopicPolicy) configEntries() (result map[string]*string) {
+	result = map[string]*string{}
+	for key, value := range this.Config {
+		v := value
+		result[key] = &v
+	}
+	return result
+}
+
+//compares the existing topics with their policies and returns the topics which have fewer partitions or replicas or different configs
+func (this Cluster) CheckTopics(topics ...string) (violations []TopicPolicyViolation, err error) {
+	brokers, err := this.GetBroker()
+	if err != nil {
+		return violations, err
+	}
+	config, err := this.saramaConfig()
+	if err != nil {
+		return violations, err
+	}
+	admin, err := sarama.NewClusterAdmin(brokers, config)
+	if err != nil {
+		return violations, err
+	}
+	defer admin.Close()
+	metadata, err := admin.DescribeTopics(topics)
+	if err != nil {
+		return violations, err
+	}
+	for _, topic := range metadata {
+		if topic.Err != sarama.ErrNoError {
+			return violations, topic.Err
+		}
+		policy := this.Topics.Get(topic.Name)
+		violation := TopicPolicyViolation{
+			Topic:                     topic.Name,
+			Partitions:                len(topic.Partitions),
+			ExpectedPartitions:        policy.Partitions,
+			ExpectedReplicationFactor: policy.ReplicationFactor,
+			Config:                    map[string]string{},
+			ExpectedConfig:            policy.Config,
+		}
+		for i, partition := range topic.Partitions {
+			if i == 0 || len(partition.Replicas) < violation.ReplicationFactor {
+				violation.ReplicationFactor = len(partition.Replicas)
+			}
+		}
+		if len(policy.Config) > 0 {
+			entries, err := admin.DescribeConfig(sarama.ConfigResource{Type: sarama.TopicResource, Name: topic.Name})
+			if err != nil {
+				return violations, err
+			}
+			current := map[string]string{}
+			for _, entry := range entries {
+				current[entry.Name] = entry.Value
+			}
+			for key, expected := range policy.Config {
+				if current[key] != expected {
+					violation.Config[key] = current[key]
+				}
+			}
+		}
+		if violation.Partitions < violation.ExpectedPartitions || violation.ReplicationFactor < violation.ExpectedReplicationFactor || len(violation.Config) > 0 {
+			violations = append(violations, violation)
+		}
+	}
+	return violations, nil
+}
+
+//reports violations to Topics.ViolationHandler or logs them; blocks while the cluster is queried
+func (this Cluster) reportTopicPolicyViolations(topics ...string) {
+	violations, err := this.CheckTopics(topics...)
+	if err != nil {
+		log.Println("WARNING: unable to check topic policy", topics, err)
+		return
+	}
+	for _, violation := range violations {
+		if this.Topics.ViolationHandler != nil {
+			this.Topics.ViolationHandler(violation)
+		} else {
+			log.Println("WARNING: topic does not satisfy its policy:", violation.String())
+		}
+	}
+}
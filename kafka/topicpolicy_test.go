@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"reflect"
+	"testing"
+)
+
+func TestTopicPolicies(t *testing.T) {
+	policies := TopicPolicies{
+		Default: TopicPolicy{Partitions: 3, ReplicationFactor: 2},
+		Overrides: []TopicPolicyOverride{
+			{Pattern: "^urn_infai_ses_service_", Policy: TopicPolicy{Partitions: 12, ReplicationFactor: 3, Config: map[string]string{"retention.ms": "604800000"}}},
+			{Pattern: "^response$", Policy: TopicPolicy{Config: map[string]string{"cleanup.policy": "delete"}}},
+		},
+	}
+	if policy := policies.Get("urn_infai_ses_service_1"); !reflect.DeepEqual(policy, policies.Overrides[0].Policy) {
+		t.Error(policy)
+	}
+	if policy := policies.Get("response"); !reflect.DeepEqual(policy, TopicPolicy{Partitions: 1, ReplicationFactor: 1, Config: map[string]string{"cleanup.policy": "delete"}}) {
+		t.Error(policy)
+	}
+	if policy := policies.Get("protocol"); !reflect.DeepEqual(policy, policies.Default) {
+		t.Error(policy)
+	}
+	if policy := (TopicPolicies{}).Get("protocol"); policy.Partitions != 1 || policy.ReplicationFactor != 1 {
+		t.Error(policy)
+	}
+}
+
+func TestCheckTopics(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	metadata := &sarama.MetadataResponse{Version: 5, ControllerID: 1}
+	metadata.AddBroker(broker.Addr(), broker.BrokerID())
+	metadata.AddTopicPartition("ok", 0, 1, []int32{1, 2}, []int32{1, 2}, nil, sarama.ErrNoError)
+	metadata.AddTopicPartition("ok", 1, 1, []int32{1, 2}, []int32{1, 2}, nil, sarama.ErrNoError)
+	metadata.AddTopicPartition("small", 0, 1, []int32{1}, []int32{1}, nil, sarama.ErrNoError)
+	metadata.AddTopicPartition("retention", 0, 1, []int32{1, 2}, []int32{1, 2}, nil, sarama.ErrNoError)
+	metadata.AddTopicPartition("retention", 1, 1, []int32{1, 2}, []int32{1, 2}, nil, sarama.ErrNoError)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":        sarama.NewMockWrapper(metadata),
+		"DescribeConfigsRequest": sarama.NewMockDescribeConfigsResponse(t), //retention.ms=5000
+	})
+	cluster := Cluster{Brokers: []string{broker.Addr()}, Topics: TopicPolicies{
+		Default: TopicPolicy{Partitions: 2, ReplicationFactor: 2},
+		Overrides: []TopicPolicyOverride{
+			{Pattern: "^retention$", Policy: TopicPolicy{Partitions: 2, ReplicationFactor: 2, Config: map[string]string{"retention.ms": "604800000"}}},
+		},
+	}}
+	violations, err := cluster.CheckTopics("ok", "small", "retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TopicPolicyViolation{
+		{Topic: "small", Partitions: 1, ExpectedPartitions: 2, ReplicationFactor: 1, ExpectedReplicationFactor: 2, Config: map[string]string{}},
+		{Topic: "retention", Partitions: 2, ExpectedPartitions: 2, ReplicationFactor: 2, ExpectedReplicationFactor: 2, Config: map[string]string{"retention.ms": "5000"}, ExpectedConfig: map[string]string{"retention.ms": "604800000"}},
+	}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Fatal(violations)
+	}
+}
@@ -22,25 +22,14 @@ import (
 	"github.com/wvanbergen/kazoo-go"
 	"io/ioutil"
 	"log"
-	"runtime/debug"
 )
 
-func EnsureTopic(topic string, zk string, knownTopics *map[string]bool)(err error){
-	if (*knownTopics)[topic] {
-		return nil
-	}
-	err = InitTopic(zk, topic)
-	if err != nil {
-		log.Println("ERROR:", err)
-		debug.PrintStack()
-		return err
-	}
-	(*knownTopics)[topic] = true
-	return
+func EnsureTopic(topic string, zk string, knownTopics *map[string]bool) (err error) {
+	return Cluster{ZookeeperUrl: zk}.EnsureTopic(topic, knownTopics)
 }
 
 func GetBroker(zk string) (brokers []string, err error) {
-	return getBroker(zk)
+	return Cluster{ZookeeperUrl: zk}.GetBroker()
 }
 
 func getBroker(zkUrl string) (brokers []string, err error) {
@@ -57,6 +46,10 @@ func getBroker(zkUrl string) (brokers []string, err error) {
 }
 
 func GetKafkaController(zkUrl string) (controller string, err error) {
+	return Cluster{ZookeeperUrl: zkUrl}.GetController()
+}
+
+func getKafkaController(zkUrl string) (controller string, err error) {
 	zookeeper := kazoo.NewConfig()
 	zookeeper.Logger = log.New(ioutil.Discard, "", 0)
 	zk, chroot := kazoo.ParseConnectionString(zkUrl)
@@ -78,11 +71,15 @@ func GetKafkaController(zkUrl string) (controller string, err error) {
 }
 
 func InitTopic(zkUrl string, topics ...string) (err error) {
-	return InitTopicWithConfig(zkUrl, 1, 1, topics...)
+	return Cluster{ZookeeperUrl: zkUrl}.InitTopic(topics...)
 }
 
 func InitTopicWithConfig(zkUrl string, numPartitions int, replicationFactor int, topics ...string) (err error) {
-	controller, err := GetKafkaController(zkUrl)
+	return Cluster{ZookeeperUrl: zkUrl}.InitTopicWithConfig(numPartitions, replicationFactor, topics...)
+}
+
+func (this Cluster) initTopicWithZookeeper(policy TopicPolicy, topics ...string) (err error) {
+	controller, err := getKafkaController(this.ZookeeperUrl)
 	if err != nil {
 		log.Println("ERROR: unable to find controller", err)
 		return err
@@ -91,17 +88,26 @@ func InitTopicWithConfig(zkUrl string, numPartitions int, replicationFactor int,
 		log.Println("ERROR: unable to find controller")
 		return errors.New("unable to find controller")
 	}
-	initConn, err := kafka.Dial("tcp", controller)
+	dialer, err := this.dialer()
+	if err != nil {
+		return err
+	}
+	initConn, err := dialer.Dial("tcp", controller)
 	if err != nil {
 		log.Println("ERROR: while init topic connection ", err)
 		return err
 	}
 	defer initConn.Close()
 	for _, topic := range topics {
+		configEntries := []kafka.ConfigEntry{}
+		for key, value := range policy.Config {
+			configEntries = append(configEntries, kafka.ConfigEntry{ConfigName: key, ConfigValue: value})
+		}
 		err = initConn.CreateTopics(kafka.TopicConfig{
 			Topic:             topic,
-			NumPartitions:     numPartitions,
-			ReplicationFactor: replicationFactor,
+			NumPartitions:     policy.Partitions,
+			ReplicationFactor: policy.ReplicationFactor,
+			ConfigEntries:     configEntries,
 		})
 		if err != nil {
 			return
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package platform_connector_lib
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+//serializes the values of commandRequest.Metadata.Service.Inputs (by ContentVariable.Name) into protocol segments.
+//ContentVariable.Value is used for missing values; contents without value are skipped
+func (this *Connector) MarshalCommandInput(commandRequest model.ProtocolMsg, values map[string]interface{}) (result CommandRequestMsg, err error) {
+	return marshalContents(this.marshallers, commandRequest.Metadata.Service.Inputs, commandRequest.Metadata.Protocol, values)
+}
+
+//serializes the values of commandRequest.Metadata.Service.Outputs (by ContentVariable.Name) into protocol segments.
+//ContentVariable.Value is used for missing values; contents without value are skipped
+func (this *Connector) MarshalCommandResponse(commandRequest model.ProtocolMsg, values map[string]interface{}) (result CommandResponseMsg, err error) {
+	return marshalContents(this.marshallers, commandRequest.Metadata.Service.Outputs, commandRequest.Metadata.Protocol, values)
+}
+
+//like HandleCommandResponse() but with structured values (see MarshalCommandResponse())
+func (this *Connector) HandleCommandResponseValues(commandRequest model.ProtocolMsg, values map[string]interface{}) (err error) {
+	commandResponse, err := this.MarshalCommandResponse(commandRequest, values)
+	if err != nil {
+		return err
+	}
+	return this.HandleCommandResponse(commandRequest, commandResponse)
+}
+
+func marshalContents(marshallers *marshalling.Registry, contents []model.Content, protocol model.Protocol, values map[string]interface{}) (result map[ProtocolSegmentName]string, err error) {
+	result = map[ProtocolSegmentName]string{}
+	for _, content := range contents {
+		value, ok := values[content.ContentVariable.Name]
+		if !ok {
+			value = content.ContentVariable.Value
+		}
+		if value == nil {
+			continue
+		}
+		segment, ok := getProtocolSegment(protocol, content.ProtocolSegmentId)
+		if !ok {
+			return result, errors.New("unknown protocol segment id " + content.ProtocolSegmentId + " for " + content.ContentVariable.Name)
+		}
+		if _, exists := result[segment.Name]; exists {
+			return result, errors.New("multiple values for protocol segment " + segment.Name)
+		}
+		marshaller, ok := marshallers.Get(content.Serialization)
+		if !ok {
+			return result, errors.New("unknown format " + content.Serialization)
+		}
+		result[segment.Name], err = marshaller.Marshal(value, content.ContentVariable)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func getProtocolSegment(protocol model.Protocol, id string) (segment model.ProtocolSegment, ok bool) {
+	for _, segment := range protocol.ProtocolSegments {
+		if segment.Id == id {
+			return segment, true
+		}
+	}
+	return segment, false
+}
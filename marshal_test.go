@@ -0,0 +1,114 @@
+package platform_connector_lib
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/conversion"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/SENERGY-Platform/platform-connector-lib/validation"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalCommandInput(t *testing.T) {
+	msg := model.ProtocolMsg{
+		Request: model.ProtocolRequest{
+			Input:  map[string]string{"header": "raw", "body": "old"},
+			Values: map[string]interface{}{"payload": map[string]interface{}{"level": 42}},
+		},
+		Metadata: model.Metadata{
+			Protocol: model.Protocol{ProtocolSegments: []model.ProtocolSegment{{Id: "s1", Name: "body"}, {Id: "s2", Name: "header"}, {Id: "s3", Name: "mode"}}},
+			Service: model.Service{Inputs: []model.Content{
+				{ContentVariable: model.ContentVariable{Name: "payload", Type: model.Structure}, Serialization: "json", ProtocolSegmentId: "s1"},
+				{ContentVariable: model.ContentVariable{Name: "mode", Type: model.String, Value: "auto"}, Serialization: "json", ProtocolSegmentId: "s3"},
+			}},
+		},
+	}
+	connector := &Connector{marshallers: marshalling.NewRegistry()}
+	result, err := connector.commandInput(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, CommandRequestMsg{"header": "raw", "body": `{"level":42}`, "mode": `"auto"`}) {
+		t.Fatal(result)
+	}
+
+	msg.Metadata.Service.Inputs[0].Serialization = "unknown"
+	_, err = connector.commandInput(msg)
+	if err == nil {
+		t.Fatal("expected unknown format error")
+	}
+}
+
+func TestMarshalCommandResponse(t *testing.T) {
+	msg := model.ProtocolMsg{
+		Metadata: model.Metadata{
+			Protocol: model.Protocol{ProtocolSegments: []model.ProtocolSegment{{Id: "s1", Name: "body"}}},
+			Service: model.Service{Outputs: []model.Content{
+				{ContentVariable: model.ContentVariable{Name: "temperature", Type: model.Float}, Serialization: "xml", ProtocolSegmentId: "s1"},
+			}},
+		},
+	}
+	connector := &Connector{marshallers: marshalling.NewRegistry()}
+	result, err := connector.MarshalCommandResponse(msg, map[string]interface{}{"temperature": 21.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, CommandResponseMsg{"body": "<temperature>21.5</temperature>"}) {
+		t.Fatal(result)
+	}
+}
+
+func TestCommandInputConversion(t *testing.T) {
+	msg := model.ProtocolMsg{
+		Request: model.ProtocolRequest{
+			Values: map[string]interface{}{"brightness": 50},
+		},
+		Metadata: model.Metadata{
+			InputCharacteristic: "percent",
+			Protocol:            model.Protocol{ProtocolSegments: []model.ProtocolSegment{{Id: "s1", Name: "body"}}},
+			Service: model.Service{Inputs: []model.Content{
+				{ContentVariable: model.ContentVariable{Name: "brightness", Type: model.Integer, CharacteristicId: "byte"}, Serialization: "json", ProtocolSegmentId: "s1"},
+			}},
+		},
+	}
+	connector := &Connector{marshallers: marshalling.NewRegistry(), conversions: conversion.New()}
+	connector.Conversions().SetUnit("percent", conversion.Percent)
+	connector.Conversions().SetCharacteristic(model.Characteristic{Id: "byte", Type: model.Integer}, conversion.ByteRange)
+	connector.Conversions().SetConcept("brightness", "percent", "byte")
+	result, err := connector.commandInput(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, CommandRequestMsg{"body": "128"}) {
+		t.Fatal(result)
+	}
+}
+
+func TestEventCharacteristics(t *testing.T) {
+	service := model.Service{Id: "service1", Outputs: []model.Content{
+		{ContentVariable: model.ContentVariable{Name: "temperature", Type: model.Float, CharacteristicId: "kelvin"}, Serialization: "json", ProtocolSegmentId: "s1"},
+		{ContentVariable: model.ContentVariable{Name: "humidity", Type: model.Float, CharacteristicId: "percent"}, Serialization: "json", ProtocolSegmentId: "s2"},
+	}}
+	protocol := model.Protocol{ProtocolSegments: []model.ProtocolSegment{{Id: "s1", Name: "temperature"}, {Id: "s2", Name: "humidity"}}}
+	connector := &Connector{marshallers: marshalling.NewRegistry(), conversions: conversion.New(), validator: validation.New(validation.Disabled)}
+	connector.Conversions().SetUnit("celsius", conversion.Celsius)
+	connector.Conversions().SetUnit("kelvin", conversion.Kelvin)
+	connector.Conversions().SetUnit("percent", conversion.Percent)
+	connector.Conversions().SetUnit("byte", conversion.ByteRange)
+	connector.Conversions().SetConcept("temperature", "celsius", "kelvin")
+	connector.Conversions().SetConcept("humidity", "percent")
+	connector.Conversions().SetConcept("brightness", "byte")
+	envelope, err := connector.unmarshalMsg("", model.Device{Id: "device1"}, service, protocol, map[string]string{"temperature": "273.15", "humidity": "40"}, "byte", "celsius")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(envelope.Value, map[string]interface{}{"temperature": 0.0, "humidity": 40.0}) {
+		t.Fatal(envelope.Value)
+	}
+	if !reflect.DeepEqual(envelope.Characteristics, map[string]string{"temperature": "celsius", "humidity": "percent"}) {
+		t.Fatal(envelope.Characteristics)
+	}
+	if envelope.DeviceId != "device1" || envelope.ServiceId != "service1" {
+		t.Fatal(envelope)
+	}
+}
@@ -2,6 +2,7 @@ package base
 
 import (
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"sort"
 	"sync"
 )
 
@@ -10,17 +11,81 @@ type Marshaller interface {
 	Unmarshal(in string, variable model.ContentVariable) (out interface{}, err error)
 }
 
+//set of marshallers by format; formats missing in a registry are looked up in its fallback registry
+type Registry struct {
+	fallback    *Registry
+	mux         sync.RWMutex
+	marshallers map[string]Marshaller
+}
+
+//fallback may be nil
+func NewRegistry(fallback *Registry) *Registry {
+	return &Registry{fallback: fallback, marshallers: map[string]Marshaller{}}
+}
+
+//deprecated: not safe for concurrent use; use Register(), Get() and List()
 var Marshallers = map[string]Marshaller{}
 
-var mux = sync.Mutex{}
+//registry used by Register() and Get(); formats of this library register themselves in init()
+var Global = &Registry{marshallers: Marshallers}
+
+func (this *Registry) Register(key string, marshaller Marshaller) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.marshallers[key] = marshaller
+}
+
+//removes the format from this registry; formats of the fallback registry are not affected
+func (this *Registry) Unregister(key string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.marshallers, key)
+}
+
+func (this *Registry) Get(key string) (marshaller Marshaller, ok bool) {
+	this.mux.RLock()
+	marshaller, ok = this.marshallers[key]
+	this.mux.RUnlock()
+	if !ok && this.fallback != nil {
+		return this.fallback.Get(key)
+	}
+	return
+}
+
+//returns the sorted formats of this registry and its fallback
+func (this *Registry) List() (result []string) {
+	formats := map[string]bool{}
+	this.collect(formats)
+	for format := range formats {
+		result = append(result, format)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (this *Registry) collect(formats map[string]bool) {
+	this.mux.RLock()
+	for format := range this.marshallers {
+		formats[format] = true
+	}
+	this.mux.RUnlock()
+	if this.fallback != nil {
+		this.fallback.collect(formats)
+	}
+}
 
 func Register(key string, marshaller Marshaller) {
-	mux.Lock()
-	defer mux.Unlock()
-	Marshallers[key] = marshaller
+	Global.Register(key, marshaller)
+}
+
+func Unregister(key string) {
+	Global.Unregister(key)
 }
 
 func Get(key string) (marshaller Marshaller, ok bool) {
-	marshaller, ok = Marshallers[key]
-	return
+	return Global.Get(key)
+}
+
+func List() []string {
+	return Global.List()
 }
@@ -0,0 +1,68 @@
+package base
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type testMarshaller string
+
+func (this testMarshaller) Marshal(in interface{}, variable model.ContentVariable) (out string, err error) {
+	return string(this), nil
+}
+
+func (this testMarshaller) Unmarshal(in string, variable model.ContentVariable) (out interface{}, err error) {
+	return string(this), nil
+}
+
+func TestRegistryFallback(t *testing.T) {
+	global := NewRegistry(nil)
+	global.Register("a", testMarshaller("global a"))
+	global.Register("b", testMarshaller("global b"))
+	local := NewRegistry(global)
+	local.Register("b", testMarshaller("local b"))
+	local.Register("c", testMarshaller("local c"))
+
+	if formats := local.List(); !reflect.DeepEqual(formats, []string{"a", "b", "c"}) {
+		t.Fatal(formats)
+	}
+	if formats := global.List(); !reflect.DeepEqual(formats, []string{"a", "b"}) {
+		t.Fatal(formats)
+	}
+	if m, ok := local.Get("b"); !ok || m != testMarshaller("local b") {
+		t.Fatal(m, ok)
+	}
+	if m, ok := local.Get("a"); !ok || m != testMarshaller("global a") {
+		t.Fatal(m, ok)
+	}
+	local.Unregister("b")
+	if m, ok := local.Get("b"); !ok || m != testMarshaller("global b") {
+		t.Fatal(m, ok)
+	}
+	if _, ok := global.Get("c"); ok {
+		t.Fatal("local format visible in fallback")
+	}
+}
+
+func TestRegistryConcurrency(t *testing.T) {
+	registry := NewRegistry(NewRegistry(nil))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := strconv.Itoa(i)
+			registry.Register(key, testMarshaller(key))
+			registry.Get(key)
+			registry.List()
+			registry.Unregister(key)
+		}(i)
+	}
+	wg.Wait()
+	if formats := registry.List(); len(formats) != 0 {
+		t.Fatal(formats)
+	}
+}
@@ -0,0 +1,68 @@
+package base
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"strings"
+)
+
+//SerializationOption of binary formats (e.g. binary_encoding=hex); describes how the bytes are carried in the string segments of events and commands:
+//	base64		standard base64 (default)
+//	base64url	url safe base64
+//	hex			hex string
+//	raw			the segment string contains the bytes unchanged;
+//				only usable for events, because command segments are transported as json and must be valid utf-8
+const BinaryEncodingOption = "binary_encoding"
+
+const (
+	Base64Encoding    = "base64"
+	Base64UrlEncoding = "base64url"
+	HexEncoding       = "hex"
+	RawEncoding       = "raw"
+)
+
+//returns the value of a key=value SerializationOption
+func GetOption(variable model.ContentVariable, key string) (value string, ok bool) {
+	for _, element := range variable.SerializationOptions {
+		if strings.HasPrefix(element, key+"=") {
+			return strings.TrimPrefix(element, key+"="), true
+		}
+	}
+	return "", false
+}
+
+//decodes the bytes of a binary segment according to the BinaryEncodingOption of variable
+func DecodeSegment(in string, variable model.ContentVariable) (out []byte, err error) {
+	encoding, _ := GetOption(variable, BinaryEncodingOption)
+	switch encoding {
+	case Base64Encoding, "":
+		return base64.StdEncoding.DecodeString(in)
+	case Base64UrlEncoding:
+		return base64.URLEncoding.DecodeString(in)
+	case HexEncoding:
+		return hex.DecodeString(in)
+	case RawEncoding:
+		return []byte(in), nil
+	default:
+		return nil, errors.New("unknown binary encoding " + encoding)
+	}
+}
+
+//encodes the bytes of a binary segment according to the BinaryEncodingOption of variable
+func EncodeSegment(in []byte, variable model.ContentVariable) (out string, err error) {
+	encoding, _ := GetOption(variable, BinaryEncodingOption)
+	switch encoding {
+	case Base64Encoding, "":
+		return base64.StdEncoding.EncodeToString(in), nil
+	case Base64UrlEncoding:
+		return base64.URLEncoding.EncodeToString(in), nil
+	case HexEncoding:
+		return hex.EncodeToString(in), nil
+	case RawEncoding:
+		return string(in), nil
+	default:
+		return "", errors.New("unknown binary encoding " + encoding)
+	}
+}
@@ -0,0 +1,223 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"strconv"
+)
+
+//sub variable name which matches all fields of a structure or all elements of a list
+const Wildcard = "*"
+
+//SerializationOption of the root ContentVariable; missing fields without default value and fields without ContentVariable are reported as errors
+const StrictOption = "strict"
+
+//coerces value to the ContentVariable.Type of the matching (sub) variable; values which can not be coerced are passed through unchanged.
+//mismatches are only returned as PathErrors if the root variable has the StrictOption.
+//absent fields are filled with ContentVariable.Value if set.
+//value may contain json.Number and maps with non string keys (e.g. from msgpack or cbor decoders)
+func Coerce(value interface{}, variable model.ContentVariable) (result interface{}, err error) {
+	walker := typeWalker{strict: HasOption(variable, StrictOption)}
+	result = walker.walk(variable.Name, value, variable)
+	if len(walker.errors) > 0 {
+		return result, walker.errors
+	}
+	return result, nil
+}
+
+func HasOption(variable model.ContentVariable, option string) bool {
+	for _, element := range variable.SerializationOptions {
+		if element == option {
+			return true
+		}
+	}
+	return false
+}
+
+type typeWalker struct {
+	strict bool
+	errors PathErrors
+}
+
+//mismatches are ignored without StrictOption
+func (this *typeWalker) mismatch(path string, format string, args ...interface{}) {
+	if !this.strict {
+		return
+	}
+	this.errors = append(this.errors, PathError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (this *typeWalker) walk(path string, value interface{}, variable model.ContentVariable) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch variable.Type {
+	case "":
+		return untyped(value)
+	case model.String:
+		str, ok := value.(string)
+		if !ok {
+			this.mismatch(path, "expected string, got %#v", untyped(value))
+			return untyped(value)
+		}
+		return str
+	case model.Integer:
+		if i, ok := ToInt64(value); ok {
+			return i
+		}
+		//unsigned values above math.MaxInt64 keep their type
+		if u, ok := ToUint64(value); ok {
+			return u
+		}
+		this.mismatch(path, "expected integer, got %#v", untyped(value))
+		return untyped(value)
+	case model.Float:
+		f, ok := ToFloat(value)
+		if !ok {
+			this.mismatch(path, "expected float, got %#v", untyped(value))
+			return untyped(value)
+		}
+		return f
+	case model.Boolean:
+		b, ok := value.(bool)
+		if !ok {
+			this.mismatch(path, "expected boolean, got %#v", untyped(value))
+			return untyped(value)
+		}
+		return b
+	case model.Structure:
+		return this.walkStructure(path, value, variable)
+	case model.List:
+		return this.walkList(path, value, variable)
+	default:
+		this.mismatch(path, "unknown type %v", variable.Type)
+		return untyped(value)
+	}
+}
+
+func (this *typeWalker) walkStructure(path string, value interface{}, variable model.ContentVariable) interface{} {
+	m, ok := stringMap(value)
+	if !ok {
+		this.mismatch(path, "expected structure, got %#v", untyped(value))
+		return untyped(value)
+	}
+	result := map[string]interface{}{}
+	var wildcard *model.ContentVariable
+	known := map[string]bool{}
+	for i, sub := range variable.SubContentVariables {
+		if sub.Name == Wildcard {
+			wildcard = &variable.SubContentVariables[i]
+			continue
+		}
+		known[sub.Name] = true
+		field, ok := m[sub.Name]
+		if !ok {
+			if sub.Value != nil {
+				result[sub.Name] = this.walk(path+"."+sub.Name, sub.Value, sub)
+			} else {
+				this.mismatch(path+"."+sub.Name, "missing field")
+			}
+			continue
+		}
+		result[sub.Name] = this.walk(path+"."+sub.Name, field, sub)
+	}
+	for key, field := range m {
+		if known[key] {
+			continue
+		}
+		if wildcard != nil {
+			result[key] = this.walk(path+"."+key, field, *wildcard)
+		} else if this.strict {
+			this.mismatch(path+"."+key, "unexpected field")
+		} else {
+			result[key] = untyped(field)
+		}
+	}
+	return result
+}
+
+//elements are matched by a Wildcard sub variable or by sub variables named by their index
+func (this *typeWalker) walkList(path string, value interface{}, variable model.ContentVariable) interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		this.mismatch(path, "expected list, got %#v", untyped(value))
+		return untyped(value)
+	}
+	var wildcard *model.ContentVariable
+	indexed := map[int]model.ContentVariable{}
+	for i, sub := range variable.SubContentVariables {
+		if sub.Name == Wildcard {
+			wildcard = &variable.SubContentVariables[i]
+		} else if index, err := strconv.Atoi(sub.Name); err == nil {
+			indexed[index] = sub
+		}
+	}
+	result := []interface{}{}
+	for i, element := range list {
+		elementPath := path + "[" + strconv.Itoa(i) + "]"
+		if sub, ok := indexed[i]; ok {
+			result = append(result, this.walk(elementPath, element, sub))
+		} else if wildcard != nil {
+			result = append(result, this.walk(elementPath, element, *wildcard))
+		} else {
+			this.mismatch(elementPath, "unexpected element")
+			result = append(result, untyped(element))
+		}
+	}
+	for i := len(list); i < len(list)+len(indexed); i++ {
+		sub, ok := indexed[i]
+		if !ok {
+			break
+		}
+		if sub.Value != nil {
+			result = append(result, this.walk(path+"["+strconv.Itoa(i)+"]", sub.Value, sub))
+		} else {
+			this.mismatch(path+"["+strconv.Itoa(i)+"]", "missing element")
+			break
+		}
+	}
+	return result
+}
+
+//replaces json.Number with float64 (the result of json.Unmarshal without ContentVariable types)
+//and map[interface{}]interface{} with map[string]interface{}
+func untyped(value interface{}) interface{} {
+	if m, ok := value.(map[interface{}]interface{}); ok {
+		value, _ = stringMap(m)
+	}
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, element := range v {
+			result[key] = untyped(element)
+		}
+		return result
+	case []interface{}:
+		result := []interface{}{}
+		for _, element := range v {
+			result = append(result, untyped(element))
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+func stringMap(value interface{}) (result map[string]interface{}, ok bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		result = map[string]interface{}{}
+		for key, element := range v {
+			result[fmt.Sprint(key)] = element
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
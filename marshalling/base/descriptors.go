@@ -0,0 +1,72 @@
+package base
+
+import (
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"sync"
+)
+
+//protobuf descriptor sets by name; used by the protobuf format to find message descriptors
+var DescriptorSets = map[string]*protoregistry.Files{}
+
+var descriptorMux = sync.RWMutex{}
+
+func RegisterDescriptorSet(name string, set *descriptorpb.FileDescriptorSet) error {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return err
+	}
+	descriptorMux.Lock()
+	defer descriptorMux.Unlock()
+	DescriptorSets[name] = files
+	return nil
+}
+
+//set is a serialized google.protobuf.FileDescriptorSet (e.g. created by protoc --descriptor_set_out --include_imports)
+func RegisterSerializedDescriptorSet(name string, set []byte) error {
+	descriptorSet := &descriptorpb.FileDescriptorSet{}
+	err := proto.Unmarshal(set, descriptorSet)
+	if err != nil {
+		return err
+	}
+	return RegisterDescriptorSet(name, descriptorSet)
+}
+
+func UnregisterDescriptorSet(name string) {
+	descriptorMux.Lock()
+	defer descriptorMux.Unlock()
+	delete(DescriptorSets, name)
+}
+
+//searches the message in the named descriptor set.
+//if setName is empty, all registered sets and the messages compiled into the binary are searched
+func FindMessageDescriptor(setName string, messageName string) (result protoreflect.MessageDescriptor, err error) {
+	descriptorMux.RLock()
+	defer descriptorMux.RUnlock()
+	sets := []*protoregistry.Files{}
+	if setName != "" {
+		set, ok := DescriptorSets[setName]
+		if !ok {
+			return nil, errors.New("unknown descriptor set " + setName)
+		}
+		sets = append(sets, set)
+	} else {
+		for _, set := range DescriptorSets {
+			sets = append(sets, set)
+		}
+		sets = append(sets, protoregistry.GlobalFiles)
+	}
+	for _, set := range sets {
+		descriptor, err := set.FindDescriptorByName(protoreflect.FullName(messageName))
+		if err == nil {
+			if result, ok := descriptor.(protoreflect.MessageDescriptor); ok {
+				return result, nil
+			}
+		}
+	}
+	return nil, errors.New("unknown protobuf message " + messageName)
+}
@@ -0,0 +1,24 @@
+package base
+
+import "strings"
+
+//describes a value which does not match the ContentVariable at Path (e.g. root.list[2].temperature)
+type PathError struct {
+	Path    string
+	Message string
+}
+
+func (this PathError) Error() string {
+	return this.Path + ": " + this.Message
+}
+
+//all mismatches found in one value
+type PathErrors []PathError
+
+func (this PathErrors) Error() string {
+	messages := []string{}
+	for _, err := range this {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,124 @@
+package base
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//returns integral values (integer types, json.Number and floats without fraction) which fit into int64;
+//integers are converted without float64 to keep their precision
+func ToInt64(in interface{}) (result int64, ok bool) {
+	switch v := in.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint, uint8, uint16, uint32, uint64:
+		u, _ := ToUint64(v)
+		return int64(u), u <= math.MaxInt64
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := v.Float64(); err == nil && !isInteger(string(v)) {
+			return ToInt64(f)
+		}
+		return 0, false
+	case float32:
+		return ToInt64(float64(v))
+	case float64:
+		//float64(math.MaxInt64) is 2^63, which does not fit
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+//returns integral values (integer types, json.Number and floats without fraction) which fit into uint64;
+//integers are converted without float64 to keep their precision
+func ToUint64(in interface{}) (result uint64, ok bool) {
+	switch v := in.(type) {
+	case uint:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case int, int8, int16, int32, int64:
+		i, _ := ToInt64(v)
+		return uint64(i), i >= 0
+	case json.Number:
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u, true
+		}
+		if f, err := v.Float64(); err == nil && !isInteger(string(v)) {
+			return ToUint64(f)
+		}
+		return 0, false
+	case float32:
+		return ToUint64(float64(v))
+	case float64:
+		//float64(math.MaxUint64) is 2^64, which does not fit
+		if v != math.Trunc(v) || v < 0 || v >= math.MaxUint64 {
+			return 0, false
+		}
+		return uint64(v), true
+	default:
+		return 0, false
+	}
+}
+
+//true for numbers without fraction and exponent; these are parsed by strconv.ParseInt or strconv.ParseUint
+func isInteger(number string) bool {
+	return !strings.ContainsAny(number, ".eE")
+}
+
+//returns numbers (integer and float types and json.Number) as float64; large integers may lose precision
+func ToFloat(value interface{}) (result float64, ok bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
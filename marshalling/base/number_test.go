@@ -0,0 +1,42 @@
+package base
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestToFloat(t *testing.T) {
+	for _, in := range []interface{}{int8(2), uint16(2), int64(2), json.Number("2"), json.Number("2.0"), float32(2), 2.0} {
+		if f, ok := ToFloat(in); !ok || f != 2 {
+			t.Error(in, f, ok)
+		}
+	}
+	for _, in := range []interface{}{"2", json.Number("two"), nil, true} {
+		if _, ok := ToFloat(in); ok {
+			t.Error(in)
+		}
+	}
+}
+
+func TestToInteger(t *testing.T) {
+	if i, ok := ToInt64(json.Number("-9007199254740993")); !ok || i != -9007199254740993 {
+		t.Error(i, ok)
+	}
+	if i, ok := ToInt64(json.Number("1e3")); !ok || i != 1000 {
+		t.Error(i, ok)
+	}
+	if u, ok := ToUint64(uint64(math.MaxUint64)); !ok || u != math.MaxUint64 {
+		t.Error(u, ok)
+	}
+	for _, in := range []interface{}{uint64(math.MaxInt64) + 1, 1.5, json.Number("9223372036854775808"), float64(math.MaxInt64)} {
+		if _, ok := ToInt64(in); ok {
+			t.Error(in)
+		}
+	}
+	for _, in := range []interface{}{-1, int64(-1), json.Number("-1"), -1.0, json.Number("18446744073709551616")} {
+		if _, ok := ToUint64(in); ok {
+			t.Error(in)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package binary
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+//packed binary frames (e.g. modbus registers or can frames); the root variable is a model.Structure whose sub variables describe the fields
+//(a root variable without sub variables describes a frame with a single field). SerializationOptions of fields:
+//	binary_offset=N			byte offset of the field (default 0)
+//	binary_length=N			byte length; 1-8 for numbers (4 or 8 for ieee floats), default 1 for booleans, required for strings
+//	binary_endian=little	byte order of the field; big endian is used by default (may be set on the root variable for all fields)
+//	binary_signed			integers are two's complement
+//	binary_scale=0.1		model.Float value = raw integer * scale; floats without scale are ieee 754
+//	binary_bitmask=0x04		only the masked bits of the field are used (e.g. flags); the value is shifted to the lowest mask bit
+//segments are encoded as described by base.BinaryEncodingOption (base64 by default)
+type Marshaller struct {
+}
+
+const Format = "binary"
+
+func init() {
+	base.Register(Format, Marshaller{})
+}
+
+func (Marshaller) Marshal(in interface{}, variable model.ContentVariable) (out string, err error) {
+	fields, err := getLayout(variable)
+	if err != nil {
+		return "", err
+	}
+	frame := make([]byte, frameLength(fields))
+	errs := base.PathErrors{}
+	for _, field := range fields {
+		value := in
+		if field.name != "" {
+			m, ok := in.(map[string]interface{})
+			if !ok {
+				return "", base.PathError{Path: variable.Name, Message: "expected structure"}
+			}
+			value, ok = m[field.name]
+			if !ok {
+				value = field.variable.Value
+			}
+			if value == nil {
+				errs = append(errs, base.PathError{Path: field.path, Message: "missing field"})
+				continue
+			}
+		}
+		err = field.encode(frame, value)
+		if err != nil {
+			errs = append(errs, base.PathError{Path: field.path, Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return "", errs
+	}
+	return base.EncodeSegment(frame, variable)
+}
+
+func (Marshaller) Unmarshal(in string, variable model.ContentVariable) (out interface{}, err error) {
+	fields, err := getLayout(variable)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := base.DecodeSegment(in, variable)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	errs := base.PathErrors{}
+	for _, field := range fields {
+		value, err := field.decode(frame)
+		if err != nil {
+			errs = append(errs, base.PathError{Path: field.path, Message: err.Error()})
+			continue
+		}
+		if field.name == "" {
+			return value, nil
+		}
+		result[field.name] = value
+	}
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
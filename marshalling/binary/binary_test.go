@@ -0,0 +1,125 @@
+package binary
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var frameVariable = model.ContentVariable{
+	Name:                 "frame",
+	Type:                 model.Structure,
+	SerializationOptions: []string{"binary_encoding=hex"},
+	SubContentVariables: []model.ContentVariable{
+		{Name: "temperature", Type: model.Float, SerializationOptions: []string{"binary_offset=0", "binary_length=2", "binary_signed", "binary_scale=0.1"}},
+		{Name: "counter", Type: model.Integer, SerializationOptions: []string{"binary_offset=2", "binary_length=4", "binary_endian=little"}},
+		{Name: "on", Type: model.Boolean, SerializationOptions: []string{"binary_offset=6", "binary_bitmask=0x01"}},
+		{Name: "mode", Type: model.Integer, SerializationOptions: []string{"binary_offset=6", "binary_length=1", "binary_bitmask=0x0e"}},
+		{Name: "power", Type: model.Float, SerializationOptions: []string{"binary_offset=7", "binary_length=4"}},
+		{Name: "name", Type: model.String, SerializationOptions: []string{"binary_offset=11", "binary_length=4"}},
+	},
+}
+
+func TestUnmarshal(t *testing.T) {
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("binary marshaller not registered")
+	}
+	//-12.5 as int16 -125; 1000 as little endian uint32; on + mode 5; 1.5 as float32; "ab"
+	out, err := marshaller.Unmarshal("ff83e8030000"+"0b"+"3fc00000"+"61620000", frameVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"temperature": -12.5,
+		"counter":     int64(1000),
+		"on":          true,
+		"mode":        int64(5),
+		"power":       1.5,
+		"name":        "ab",
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatal(out)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	out, err := marshaller.Marshal(map[string]interface{}{
+		"temperature": -12.5,
+		"counter":     int64(1000),
+		"on":          true,
+		"mode":        int64(5),
+		"power":       1.5,
+		"name":        "ab",
+	}, frameVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "ff83e80300000b3fc0000061620000" {
+		t.Fatal(out)
+	}
+}
+
+func TestMarshalRange(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	_, err := marshaller.Marshal(map[string]interface{}{
+		"temperature": 4000.0,
+		"counter":     int64(-1),
+		"on":          false,
+		"mode":        int64(8),
+		"power":       1.5,
+		"name":        "abcde",
+	}, frameVariable)
+	pathErrors, ok := err.(base.PathErrors)
+	if !ok || len(pathErrors) != 4 {
+		t.Fatal(err)
+	}
+}
+
+func TestSingleValue(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	variable := model.ContentVariable{Name: "register", Type: model.Integer, SerializationOptions: []string{"binary_length=2", "binary_encoding=raw"}}
+	out, err := marshaller.Unmarshal("\x01\x02", variable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != int64(258) {
+		t.Fatal(out)
+	}
+	serialized, err := marshaller.Marshal(258, variable)
+	if err != nil || serialized != "\x01\x02" {
+		t.Fatal(serialized, err)
+	}
+}
+
+func TestLargeIntegers(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	variable := model.ContentVariable{
+		Name:                 "frame",
+		Type:                 model.Structure,
+		SerializationOptions: []string{"binary_encoding=hex"},
+		SubContentVariables: []model.ContentVariable{
+			{Name: "unsigned", Type: model.Integer, SerializationOptions: []string{"binary_offset=0", "binary_length=8"}},
+			{Name: "signed", Type: model.Integer, SerializationOptions: []string{"binary_offset=8", "binary_length=8", "binary_signed"}},
+		},
+	}
+	out, err := marshaller.Unmarshal("ffffffffffffffff"+"8000000000000001", variable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"unsigned": uint64(math.MaxUint64), "signed": int64(math.MinInt64 + 1)}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatal(out)
+	}
+	serialized, err := marshaller.Marshal(map[string]interface{}{"unsigned": uint64(1<<63 + 1), "signed": int64(1<<53 + 1)}, variable)
+	if err != nil || serialized != "8000000000000001"+"0020000000000001" {
+		t.Fatal(serialized, err)
+	}
+	_, err = marshaller.Marshal(map[string]interface{}{"unsigned": -1, "signed": 0}, variable)
+	if err == nil {
+		t.Fatal("expected range error")
+	}
+}
@@ -0,0 +1,294 @@
+package binary
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const (
+	OffsetOption  = "binary_offset"
+	LengthOption  = "binary_length"
+	EndianOption  = "binary_endian"
+	SignedOption  = "binary_signed"
+	ScaleOption   = "binary_scale"
+	BitmaskOption = "binary_bitmask"
+)
+
+type field struct {
+	name         string //empty for a root variable without sub variables
+	path         string
+	variable     model.ContentVariable
+	offset       int
+	length       int
+	littleEndian bool
+	signed       bool
+	scale        float64
+	bitmask      uint64
+}
+
+func getLayout(variable model.ContentVariable) (result []field, err error) {
+	rootEndian, _ := base.GetOption(variable, EndianOption)
+	if len(variable.SubContentVariables) == 0 {
+		f, err := getField(variable, variable.Name, rootEndian)
+		if err != nil {
+			return result, err
+		}
+		f.name = ""
+		return []field{f}, nil
+	}
+	if variable.Type != model.Structure && variable.Type != "" {
+		return result, errors.New("binary root variable " + variable.Name + " must be a structure")
+	}
+	for _, sub := range variable.SubContentVariables {
+		f, err := getField(sub, variable.Name+"."+sub.Name, rootEndian)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+func getField(variable model.ContentVariable, path string, defaultEndian string) (result field, err error) {
+	result = field{name: variable.Name, path: path, variable: variable}
+	if offset, ok := base.GetOption(variable, OffsetOption); ok {
+		result.offset, err = strconv.Atoi(offset)
+		if err != nil || result.offset < 0 {
+			return result, errors.New("invalid " + OffsetOption + " of " + path)
+		}
+	}
+	if length, ok := base.GetOption(variable, LengthOption); ok {
+		result.length, err = strconv.Atoi(length)
+		if err != nil || result.length < 1 {
+			return result, errors.New("invalid " + LengthOption + " of " + path)
+		}
+	} else if variable.Type == model.Boolean {
+		result.length = 1
+	} else {
+		return result, errors.New("missing " + LengthOption + " of " + path)
+	}
+	endian, ok := base.GetOption(variable, EndianOption)
+	if !ok {
+		endian = defaultEndian
+	}
+	switch endian {
+	case "little":
+		result.littleEndian = true
+	case "big", "":
+	default:
+		return result, errors.New("invalid " + EndianOption + " of " + path)
+	}
+	result.signed = base.HasOption(variable, SignedOption)
+	if scale, ok := base.GetOption(variable, ScaleOption); ok {
+		result.scale, err = strconv.ParseFloat(scale, 64)
+		if err != nil || result.scale == 0 {
+			return result, errors.New("invalid " + ScaleOption + " of " + path)
+		}
+	}
+	if bitmask, ok := base.GetOption(variable, BitmaskOption); ok {
+		result.bitmask, err = strconv.ParseUint(bitmask, 0, 64)
+		if err != nil || result.bitmask == 0 {
+			return result, errors.New("invalid " + BitmaskOption + " of " + path)
+		}
+	}
+	switch variable.Type {
+	case model.String:
+	case model.Float:
+		if result.scale == 0 && result.length != 4 && result.length != 8 {
+			return result, errors.New(path + ": ieee floats need a length of 4 or 8")
+		}
+		fallthrough
+	default:
+		if result.length > 8 {
+			return result, errors.New(path + ": numbers may not be longer than 8 bytes")
+		}
+	}
+	return result, nil
+}
+
+func frameLength(fields []field) (result int) {
+	for _, f := range fields {
+		if end := f.offset + f.length; end > result {
+			result = end
+		}
+	}
+	return result
+}
+
+func (this field) readRaw(frame []byte) (result uint64, err error) {
+	if this.offset+this.length > len(frame) {
+		return 0, fmt.Errorf("frame of %v bytes too short for offset %v and length %v", len(frame), this.offset, this.length)
+	}
+	for i := 0; i < this.length; i++ {
+		index := this.offset + i
+		if this.littleEndian {
+			index = this.offset + this.length - 1 - i
+		}
+		result = result<<8 | uint64(frame[index])
+	}
+	return result, nil
+}
+
+func (this field) writeRaw(frame []byte, value uint64) {
+	for i := this.length - 1; i >= 0; i-- {
+		index := this.offset + i
+		if this.littleEndian {
+			index = this.offset + this.length - 1 - i
+		}
+		frame[index] = byte(value)
+		value = value >> 8
+	}
+}
+
+func (this field) bitLength() int {
+	if this.bitmask != 0 {
+		return bits.OnesCount64(this.bitmask)
+	}
+	return this.length * 8
+}
+
+func (this field) decode(frame []byte) (result interface{}, err error) {
+	if this.variable.Type == model.String {
+		if this.offset+this.length > len(frame) {
+			return nil, fmt.Errorf("frame of %v bytes too short for offset %v and length %v", len(frame), this.offset, this.length)
+		}
+		return strings.TrimRight(string(frame[this.offset:this.offset+this.length]), "\x00"), nil
+	}
+	raw, err := this.readRaw(frame)
+	if err != nil {
+		return nil, err
+	}
+	if this.bitmask != 0 {
+		raw = (raw & this.bitmask) >> uint(bits.TrailingZeros64(this.bitmask))
+	}
+	if this.variable.Type == model.Boolean {
+		return raw != 0, nil
+	}
+	if this.variable.Type == model.Float && this.scale == 0 {
+		if this.length == 4 {
+			return float64(math.Float32frombits(uint32(raw))), nil
+		}
+		return math.Float64frombits(raw), nil
+	}
+	if !this.signed {
+		//unsigned values above math.MaxInt64 are returned as uint64
+		if this.variable.Type == model.Float {
+			return float64(raw) * this.scale, nil
+		}
+		if this.scale != 0 {
+			return int64(math.Round(float64(raw) * this.scale)), nil
+		}
+		if raw > math.MaxInt64 {
+			return raw, nil
+		}
+		return int64(raw), nil
+	}
+	shift := uint(64 - this.bitLength())
+	integer := int64(raw<<shift) >> shift
+	if this.variable.Type == model.Float {
+		return float64(integer) * this.scale, nil
+	}
+	if this.scale != 0 {
+		return int64(math.Round(float64(integer) * this.scale)), nil
+	}
+	return integer, nil
+}
+
+func (this field) encode(frame []byte, value interface{}) (err error) {
+	var raw uint64
+	switch this.variable.Type {
+	case model.String:
+		str, ok := value.(string)
+		if !ok || len(str) > this.length {
+			return fmt.Errorf("%#v is no string with up to %v bytes", value, this.length)
+		}
+		copy(frame[this.offset:this.offset+this.length], str)
+		return nil
+	case model.Boolean:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expected boolean, got %#v", value)
+		}
+		if b {
+			raw = 1
+			if this.bitmask != 0 {
+				raw = this.bitmask >> uint(bits.TrailingZeros64(this.bitmask))
+			}
+		}
+	default:
+		f, ok := base.ToFloat(value)
+		if !ok {
+			return fmt.Errorf("expected number, got %#v", value)
+		}
+		if this.variable.Type != model.Float && this.scale == 0 {
+			raw, err = this.integerToRaw(value)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		if this.variable.Type == model.Float && this.scale == 0 {
+			if this.length == 4 {
+				raw = uint64(math.Float32bits(float32(f)))
+			} else {
+				raw = math.Float64bits(f)
+			}
+			break
+		}
+		if this.scale != 0 {
+			f = math.Round(f / this.scale)
+		}
+		raw, err = this.integerToRaw(f)
+		if err != nil {
+			return err
+		}
+	}
+	if this.bitmask != 0 {
+		existing, err := this.readRaw(frame)
+		if err != nil {
+			return err
+		}
+		raw = existing&^this.bitmask | (raw<<uint(bits.TrailingZeros64(this.bitmask)))&this.bitmask
+	}
+	this.writeRaw(frame, raw)
+	return nil
+}
+
+//value is converted without float64 to keep the precision of 64 bit integers
+func (this field) integerToRaw(value interface{}) (raw uint64, err error) {
+	bitLength := uint(this.bitLength())
+	if this.signed {
+		i, ok := base.ToInt64(value)
+		if !ok {
+			if _, isNumber := base.ToFloat(value); isNumber {
+				return 0, fmt.Errorf("%v is no integer or does not fit into %v signed bits", value, bitLength)
+			}
+			return 0, fmt.Errorf("expected integer, got %#v", value)
+		}
+		if bitLength < 64 && (i < -(1<<(bitLength-1)) || i >= 1<<(bitLength-1)) {
+			return 0, fmt.Errorf("%v does not fit into %v signed bits", value, bitLength)
+		}
+		raw = uint64(i)
+		if bitLength < 64 {
+			raw = raw & (1<<bitLength - 1)
+		}
+		return raw, nil
+	}
+	raw, ok := base.ToUint64(value)
+	if !ok {
+		if _, isNumber := base.ToFloat(value); isNumber {
+			return 0, fmt.Errorf("%v is no integer or does not fit into %v unsigned bits", value, bitLength)
+		}
+		return 0, fmt.Errorf("expected integer, got %#v", value)
+	}
+	if bitLength < 64 && raw >= 1<<bitLength {
+		return 0, fmt.Errorf("%v does not fit into %v unsigned bits", value, bitLength)
+	}
+	return raw, nil
+}
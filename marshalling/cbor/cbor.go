@@ -0,0 +1,41 @@
+package cbor
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/ugorji/go/codec"
+)
+
+//segments are encoded as described by base.BinaryEncodingOption (base64 by default)
+type Marshaller struct {
+}
+
+const Format = "cbor"
+
+var handle = &codec.CborHandle{}
+
+func init() {
+	base.Register(Format, Marshaller{})
+}
+
+func (Marshaller) Marshal(in interface{}, variable model.ContentVariable) (out string, err error) {
+	temp := []byte{}
+	err = codec.NewEncoderBytes(&temp, handle).Encode(in)
+	if err != nil {
+		return "", err
+	}
+	return base.EncodeSegment(temp, variable)
+}
+
+//values are coerced like the json format (see base.Coerce()); non string map keys (e.g. integers) are converted to strings
+func (Marshaller) Unmarshal(in string, variable model.ContentVariable) (out interface{}, err error) {
+	temp, err := base.DecodeSegment(in, variable)
+	if err != nil {
+		return nil, err
+	}
+	err = codec.NewDecoderBytes(temp, handle).Decode(&out)
+	if err != nil {
+		return nil, err
+	}
+	return base.Coerce(out, variable)
+}
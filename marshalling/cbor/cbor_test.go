@@ -0,0 +1,111 @@
+package cbor
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var variable = model.ContentVariable{
+	Name: "reading",
+	Type: model.Structure,
+	SubContentVariables: []model.ContentVariable{
+		{Name: "level", Type: model.Integer},
+		{Name: "temperature", Type: model.Float},
+		{Name: "unit", Type: model.String},
+	},
+}
+
+func TestUnmarshal(t *testing.T) {
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("cbor marshaller not registered")
+	}
+	hexVariable := variable
+	hexVariable.SerializationOptions = []string{"binary_encoding=hex"}
+	//{"level": 42, "temperature": 21.5, "unit": "C"}
+	out, err := marshaller.Unmarshal("a3656c6576656c182a6b74656d7065726174757265f94d6064756e69746143", hexVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"level": int64(42), "temperature": 21.5, "unit": "C"}) {
+		t.Fatal(out)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	for _, encoding := range []string{"base64", "base64url", "hex", "raw"} {
+		v := variable
+		v.SerializationOptions = []string{"binary_encoding=" + encoding}
+		value := map[string]interface{}{"level": int64(-3), "temperature": 21.25, "unit": "°C"}
+		serialized, err := marshaller.Marshal(value, v)
+		if err != nil {
+			t.Fatal(encoding, err)
+		}
+		out, err := marshaller.Unmarshal(serialized, v)
+		if err != nil {
+			t.Fatal(encoding, err)
+		}
+		if !reflect.DeepEqual(out, value) {
+			t.Fatal(encoding, out)
+		}
+	}
+}
+
+var quirksVariable = model.ContentVariable{
+	Name:                 "reading",
+	Type:                 model.Structure,
+	SerializationOptions: []string{"binary_encoding=hex"},
+	SubContentVariables: []model.ContentVariable{
+		{Name: "1", Type: model.String},
+		{Name: "counter", Type: model.Integer},
+		{Name: "offset", Type: model.Integer},
+		{Name: "raw"},
+	},
+}
+
+func TestNonStringKeys(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	//{1: "a", 2: {3: true}}
+	out, err := marshaller.Unmarshal("a2016161"+"02a103f5", quirksVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"1": "a", "2": map[string]interface{}{"3": true}}) {
+		t.Fatal(out)
+	}
+}
+
+func TestIntegerWidths(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	//{"counter": 2^64-1 (major type 0 with 8 byte argument), "offset": -2^63 (major type 1)}
+	out, err := marshaller.Unmarshal("a2"+"67636f756e746572"+"1bffffffffffffffff"+"666f6666736574"+"3b7fffffffffffffff", quirksVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"counter": uint64(math.MaxUint64), "offset": int64(math.MinInt64)}) {
+		t.Fatal(out)
+	}
+}
+
+func TestByteStrings(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	//{"raw": h'0102', "1": h'61'}; byte strings are not text strings and stay []byte
+	out, err := marshaller.Unmarshal("a2"+"63726177420102"+"6131"+"4161", quirksVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"raw": []byte{1, 2}, "1": []byte("a")}) {
+		t.Fatal(out)
+	}
+	strict := quirksVariable
+	strict.SerializationOptions = []string{"binary_encoding=hex", base.StrictOption}
+	strict.SubContentVariables = []model.ContentVariable{{Name: "1", Type: model.String}}
+	_, err = marshaller.Unmarshal("a1"+"6131"+"4161", strict)
+	if _, ok := err.(base.PathErrors); !ok {
+		t.Fatal(err)
+	}
+}
@@ -2,8 +2,11 @@ package json
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"io"
+	"strings"
 )
 
 type Marshaller struct {
@@ -11,6 +14,8 @@ type Marshaller struct {
 
 const Format = "json"
 
+const StrictOption = base.StrictOption
+
 func init() {
 	base.Register(Format, Marshaller{})
 }
@@ -23,7 +28,17 @@ func (Marshaller) Marshal(in interface{}, variable model.ContentVariable) (out s
 	return string(temp), err
 }
 
+//values are coerced to the ContentVariable.Type of the matching sub variable; mismatches are passed through unchanged or returned as base.PathErrors with StrictOption
+//absent fields are filled with ContentVariable.Value if set
 func (Marshaller) Unmarshal(in string, variable model.ContentVariable) (out interface{}, err error) {
-	err = json.Unmarshal([]byte(in), &out)
-	return
+	decoder := json.NewDecoder(strings.NewReader(in))
+	decoder.UseNumber()
+	err = decoder.Decode(&out)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after json value")
+	}
+	return base.Coerce(out, variable)
 }
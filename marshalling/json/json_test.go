@@ -0,0 +1,92 @@
+package json
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"reflect"
+	"testing"
+)
+
+var exampleVariable = model.ContentVariable{
+	Name: "example",
+	Type: model.Structure,
+	SubContentVariables: []model.ContentVariable{
+		{Name: "level", Type: model.Integer},
+		{Name: "temperature", Type: model.Float},
+		{Name: "on", Type: model.Boolean, Value: false},
+		{Name: "unit", Type: model.String},
+		{Name: "history", Type: model.List, SubContentVariables: []model.ContentVariable{{Name: "*", Type: model.Integer}}},
+	},
+}
+
+func TestUnmarshalUntyped(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	out, err := marshaller.Unmarshal(`{"a":1,"b":[true,"c"]}`, model.ContentVariable{Name: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"a": float64(1), "b": []interface{}{true, "c"}}) {
+		t.Fatal(out)
+	}
+}
+
+func TestUnmarshalTyped(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	out, err := marshaller.Unmarshal(`{"level":42,"temperature":21,"unit":"°C","history":[1,2],"extra":1}`, exampleVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"level":       int64(42),
+		"temperature": float64(21),
+		"on":          false,
+		"unit":        "°C",
+		"history":     []interface{}{int64(1), int64(2)},
+		"extra":       float64(1),
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatal(out)
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	variable := exampleVariable
+	variable.SerializationOptions = []string{StrictOption}
+	_, err := marshaller.Unmarshal(`{"level":4.2,"temperature":"warm","history":[1,"2"],"extra":1}`, variable)
+	pathErrors, ok := err.(base.PathErrors)
+	if !ok {
+		t.Fatal(err)
+	}
+	paths := map[string]bool{}
+	for _, e := range pathErrors {
+		paths[e.Path] = true
+	}
+	expected := map[string]bool{
+		"example.level":       true,
+		"example.temperature": true,
+		"example.unit":        true,
+		"example.history[1]":  true,
+		"example.extra":       true,
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatal(pathErrors)
+	}
+}
+
+func TestUnmarshalMismatchWithoutStrict(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	out, err := marshaller.Unmarshal(`{"level":4.2,"temperature":"warm","history":[1,"2"]}`, exampleVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"level":       4.2,
+		"temperature": "warm",
+		"on":          false,
+		"history":     []interface{}{int64(1), "2"},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatal(out)
+	}
+}
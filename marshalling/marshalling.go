@@ -2,10 +2,26 @@ package marshalling
 
 import (
 	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	_ "github.com/SENERGY-Platform/platform-connector-lib/marshalling/binary"
+	_ "github.com/SENERGY-Platform/platform-connector-lib/marshalling/cbor"
 	_ "github.com/SENERGY-Platform/platform-connector-lib/marshalling/json"
+	_ "github.com/SENERGY-Platform/platform-connector-lib/marshalling/msgpack"
+	_ "github.com/SENERGY-Platform/platform-connector-lib/marshalling/plaintext"
+	_ "github.com/SENERGY-Platform/platform-connector-lib/marshalling/protobuf"
 	_ "github.com/SENERGY-Platform/platform-connector-lib/marshalling/xml"
 )
 
 func Get(key string) (marshaller base.Marshaller, ok bool) {
 	return base.Get(key)
 }
+
+func List() []string {
+	return base.List()
+}
+
+type Registry = base.Registry
+
+//returns a registry which uses the global registry for formats it does not contain
+func NewRegistry() *Registry {
+	return base.NewRegistry(base.Global)
+}
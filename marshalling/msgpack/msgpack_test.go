@@ -0,0 +1,112 @@
+package msgpack
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var variable = model.ContentVariable{
+	Name: "reading",
+	Type: model.Structure,
+	SubContentVariables: []model.ContentVariable{
+		{Name: "level", Type: model.Integer},
+		{Name: "temperature", Type: model.Float},
+		{Name: "unit", Type: model.String},
+	},
+}
+
+func TestUnmarshal(t *testing.T) {
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("msgpack marshaller not registered")
+	}
+	hexVariable := variable
+	hexVariable.SerializationOptions = []string{"binary_encoding=hex"}
+	//{"level": 42, "temperature": 21.5, "unit": "C"}
+	out, err := marshaller.Unmarshal("83a56c6576656c2aab74656d7065726174757265cb4035800000000000a4756e6974a143", hexVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"level": int64(42), "temperature": 21.5, "unit": "C"}) {
+		t.Fatal(out)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	for _, encoding := range []string{"base64", "base64url", "hex", "raw"} {
+		v := variable
+		v.SerializationOptions = []string{"binary_encoding=" + encoding}
+		value := map[string]interface{}{"level": int64(-3), "temperature": 21.25, "unit": "°C"}
+		serialized, err := marshaller.Marshal(value, v)
+		if err != nil {
+			t.Fatal(encoding, err)
+		}
+		out, err := marshaller.Unmarshal(serialized, v)
+		if err != nil {
+			t.Fatal(encoding, err)
+		}
+		if !reflect.DeepEqual(out, value) {
+			t.Fatal(encoding, out)
+		}
+	}
+}
+
+var quirksVariable = model.ContentVariable{
+	Name:                 "reading",
+	Type:                 model.Structure,
+	SerializationOptions: []string{"binary_encoding=hex"},
+	SubContentVariables: []model.ContentVariable{
+		{Name: "1", Type: model.String},
+		{Name: "counter", Type: model.Integer},
+		{Name: "offset", Type: model.Integer},
+		{Name: "raw", Type: model.String},
+	},
+}
+
+func TestNonStringKeys(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	//{1: "a", 2: {3: true}}
+	out, err := marshaller.Unmarshal("8201a161"+"028103c3", quirksVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"1": "a", "2": map[string]interface{}{"3": true}}) {
+		t.Fatal(out)
+	}
+}
+
+func TestIntegerWidths(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	//{"counter": 2^64-1 as uint 64, "offset": -2^63 as int 64}
+	out, err := marshaller.Unmarshal("82"+"a7636f756e746572"+"cfffffffffffffffff"+"a66f6666736574"+"d38000000000000000", quirksVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"counter": uint64(math.MaxUint64), "offset": int64(math.MinInt64)}) {
+		t.Fatal(out)
+	}
+	//{"counter": 200 as uint 8, "offset": -5 as negative fixint}
+	out, err = marshaller.Unmarshal("82"+"a7636f756e746572"+"ccc8"+"a66f6666736574"+"fb", quirksVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"counter": int64(200), "offset": int64(-5)}) {
+		t.Fatal(out)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	//{"raw": bin 8 0x0102}; bin and str are both decoded as string
+	out, err := marshaller.Unmarshal("81"+"a3726177"+"c4020102", quirksVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]interface{}{"raw": "\x01\x02"}) {
+		t.Fatal(out)
+	}
+}
@@ -0,0 +1,144 @@
+package plaintext
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"strconv"
+	"strings"
+)
+
+//serializes scalar values as bare text (e.g. 23.5, true, ON) according to ContentVariable.Type
+//supported ContentVariable.SerializationOptions:
+//	decimal_separator=,		used for model.Float (default '.')
+//	true=ON|on|1			accepted text for true; the first entry is used by Marshal (default 'true')
+//	false=OFF|off|0			accepted text for false; the first entry is used by Marshal (default 'false')
+type Marshaller struct {
+}
+
+const Format = "plain-text"
+
+func init() {
+	base.Register(Format, Marshaller{})
+}
+
+type options struct {
+	decimalSeparator string
+	trueValues       []string
+	falseValues      []string
+}
+
+func parseOptions(variable model.ContentVariable) (result options, err error) {
+	result = options{decimalSeparator: ".", trueValues: []string{"true"}, falseValues: []string{"false"}}
+	for _, option := range variable.SerializationOptions {
+		parts := strings.SplitN(option, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return result, errors.New("invalid serialization option '" + option + "' for " + variable.Name)
+		}
+		switch parts[0] {
+		case "decimal_separator":
+			result.decimalSeparator = parts[1]
+		case "true":
+			result.trueValues = strings.Split(parts[1], "|")
+		case "false":
+			result.falseValues = strings.Split(parts[1], "|")
+		default:
+			return result, errors.New("unknown serialization option '" + option + "' for " + variable.Name)
+		}
+	}
+	return result, nil
+}
+
+func typeError(value interface{}, variable model.ContentVariable) error {
+	return fmt.Errorf("%v value %#v does not fit type %v", variable.Name, value, variable.Type)
+}
+
+func (Marshaller) Marshal(in interface{}, variable model.ContentVariable) (out string, err error) {
+	opt, err := parseOptions(variable)
+	if err != nil {
+		return "", err
+	}
+	switch variable.Type {
+	case model.String:
+		str, ok := in.(string)
+		if !ok {
+			return "", typeError(in, variable)
+		}
+		return str, nil
+	case model.Integer:
+		//exact conversion; values which are not integral or do not fit into int64/uint64 are rejected
+		if i, ok := base.ToInt64(in); ok {
+			return strconv.FormatInt(i, 10), nil
+		}
+		if u, ok := base.ToUint64(in); ok {
+			return strconv.FormatUint(u, 10), nil
+		}
+		return "", typeError(in, variable)
+	case model.Float:
+		f, ok := base.ToFloat(in)
+		if !ok {
+			return "", typeError(in, variable)
+		}
+		return strings.Replace(strconv.FormatFloat(f, 'f', -1, 64), ".", opt.decimalSeparator, 1), nil
+	case model.Boolean:
+		b, ok := in.(bool)
+		if !ok {
+			return "", typeError(in, variable)
+		}
+		if b {
+			return opt.trueValues[0], nil
+		}
+		return opt.falseValues[0], nil
+	case "":
+		switch in.(type) {
+		case map[string]interface{}, []interface{}:
+			return "", typeError(in, variable)
+		}
+		return fmt.Sprint(in), nil
+	default:
+		return "", errors.New("plain-text does not support type " + string(variable.Type) + " of " + variable.Name)
+	}
+}
+
+func (Marshaller) Unmarshal(in string, variable model.ContentVariable) (out interface{}, err error) {
+	opt, err := parseOptions(variable)
+	if err != nil {
+		return nil, err
+	}
+	switch variable.Type {
+	case model.String, "":
+		return in, nil
+	case model.Integer:
+		trimmed := strings.TrimSpace(in)
+		if i, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return i, nil
+		}
+		//values above math.MaxInt64 are returned as uint64
+		if u, err := strconv.ParseUint(trimmed, 10, 64); err == nil {
+			return u, nil
+		}
+		return nil, typeError(in, variable)
+	case model.Float:
+		out, err = strconv.ParseFloat(strings.Replace(strings.TrimSpace(in), opt.decimalSeparator, ".", 1), 64)
+		if err != nil {
+			return nil, typeError(in, variable)
+		}
+		return out, nil
+	case model.Boolean:
+		trimmed := strings.TrimSpace(in)
+		for _, candidate := range opt.trueValues {
+			if trimmed == candidate {
+				return true, nil
+			}
+		}
+		for _, candidate := range opt.falseValues {
+			if trimmed == candidate {
+				return false, nil
+			}
+		}
+		return nil, typeError(in, variable)
+	default:
+		return nil, errors.New("plain-text does not support type " + string(variable.Type) + " of " + variable.Name)
+	}
+}
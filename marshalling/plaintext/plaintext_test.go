@@ -0,0 +1,126 @@
+package plaintext
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalScalars(t *testing.T) {
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("plain-text marshaller not registered")
+	}
+	cases := []struct {
+		in       string
+		variable model.ContentVariable
+		out      interface{}
+	}{
+		{"23.5", model.ContentVariable{Name: "f", Type: model.Float}, 23.5},
+		{" 23,5 ", model.ContentVariable{Name: "f", Type: model.Float, SerializationOptions: []string{"decimal_separator=,"}}, 23.5},
+		{"42", model.ContentVariable{Name: "i", Type: model.Integer}, int64(42)},
+		{"true", model.ContentVariable{Name: "b", Type: model.Boolean}, true},
+		{"off", model.ContentVariable{Name: "b", Type: model.Boolean, SerializationOptions: []string{"true=ON|on", "false=OFF|off"}}, false},
+		{"foo", model.ContentVariable{Name: "s", Type: model.String}, "foo"},
+	}
+	for _, c := range cases {
+		out, err := marshaller.Unmarshal(c.in, c.variable)
+		if err != nil {
+			t.Fatal(c.in, err)
+		}
+		if !reflect.DeepEqual(out, c.out) {
+			t.Fatal(c.in, out)
+		}
+	}
+}
+
+func TestUnmarshalTypeMismatch(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	_, err := marshaller.Unmarshal("23.5", model.ContentVariable{Name: "i", Type: model.Integer})
+	if err == nil {
+		t.Fatal("expected error for float as integer")
+	}
+	_, err = marshaller.Unmarshal("ON", model.ContentVariable{Name: "b", Type: model.Boolean})
+	if err == nil {
+		t.Fatal("expected error for unknown boolean")
+	}
+	_, err = marshaller.Unmarshal("1", model.ContentVariable{Name: "l", Type: model.List})
+	if err == nil {
+		t.Fatal("expected error for list")
+	}
+}
+
+func TestMarshalScalars(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	cases := []struct {
+		in       interface{}
+		variable model.ContentVariable
+		out      string
+	}{
+		{23.5, model.ContentVariable{Name: "f", Type: model.Float, SerializationOptions: []string{"decimal_separator=,"}}, "23,5"},
+		{float64(42), model.ContentVariable{Name: "i", Type: model.Integer}, "42"},
+		{true, model.ContentVariable{Name: "b", Type: model.Boolean, SerializationOptions: []string{"true=ON|on"}}, "ON"},
+		{"foo", model.ContentVariable{Name: "s", Type: model.String}, "foo"},
+	}
+	for _, c := range cases {
+		out, err := marshaller.Marshal(c.in, c.variable)
+		if err != nil {
+			t.Fatal(c.in, err)
+		}
+		if out != c.out {
+			t.Fatal(c.in, out)
+		}
+	}
+	_, err := marshaller.Marshal(4.2, model.ContentVariable{Name: "i", Type: model.Integer})
+	if err == nil {
+		t.Fatal("expected error for fraction as integer")
+	}
+	_, err = marshaller.Marshal("true", model.ContentVariable{Name: "b", Type: model.Boolean})
+	if err == nil {
+		t.Fatal("expected error for string as boolean")
+	}
+}
+
+func TestLargeIntegers(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	variable := model.ContentVariable{Name: "i", Type: model.Integer}
+	cases := []struct {
+		in  interface{}
+		out string
+	}{
+		{int64(9007199254740993), "9007199254740993"}, //2^53+1
+		{uint64(math.MaxInt64) + 1, "9223372036854775808"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{int64(math.MinInt64), "-9223372036854775808"},
+	}
+	for _, c := range cases {
+		out, err := marshaller.Marshal(c.in, variable)
+		if err != nil || out != c.out {
+			t.Fatal(c.in, out, err)
+		}
+		back, err := marshaller.Unmarshal(out, variable)
+		if err != nil {
+			t.Fatal(out, err)
+		}
+		if !reflect.DeepEqual(back, c.in) {
+			t.Fatalf("%#v %#v", c.in, back)
+		}
+	}
+}
+
+func TestIntegerOutOfRange(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	variable := model.ContentVariable{Name: "i", Type: model.Integer}
+	for _, in := range []interface{}{1e20, float64(math.MaxUint64), 2.5, "1"} {
+		out, err := marshaller.Marshal(in, variable)
+		if err == nil {
+			t.Fatal(in, out)
+		}
+	}
+	out, err := marshaller.Unmarshal("18446744073709551616", variable) //2^64
+	if err == nil {
+		t.Fatal(out)
+	}
+}
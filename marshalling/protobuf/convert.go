@@ -0,0 +1,229 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"math"
+	"strconv"
+)
+
+type decoder struct {
+	errors base.PathErrors
+}
+
+//scalar, list and map fields are always set (with proto3 defaults); message fields only if present
+func (this *decoder) message(path string, msg protoreflect.Message) map[string]interface{} {
+	if unknown := msg.GetUnknown(); len(unknown) > 0 {
+		this.errors = append(this.errors, base.PathError{Path: path, Message: fmt.Sprintf("%v bytes of unknown fields", len(unknown))})
+	}
+	result := map[string]interface{}{}
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.Message() != nil && !field.IsList() && !field.IsMap() && !msg.Has(field) {
+			continue
+		}
+		if field.ContainingOneof() != nil && !msg.Has(field) {
+			continue
+		}
+		name := string(field.Name())
+		value := msg.Get(field)
+		switch {
+		case field.IsList():
+			list := []interface{}{}
+			for j := 0; j < value.List().Len(); j++ {
+				list = append(list, this.value(path+"."+name+"["+strconv.Itoa(j)+"]", field, value.List().Get(j)))
+			}
+			result[name] = list
+		case field.IsMap():
+			m := map[string]interface{}{}
+			value.Map().Range(func(key protoreflect.MapKey, element protoreflect.Value) bool {
+				m[key.String()] = this.value(path+"."+name+"."+key.String(), field.MapValue(), element)
+				return true
+			})
+			result[name] = m
+		default:
+			result[name] = this.value(path+"."+name, field, value)
+		}
+	}
+	return result
+}
+
+func (this *decoder) value(path string, field protoreflect.FieldDescriptor, value protoreflect.Value) interface{} {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return this.message(path, value.Message())
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByNumber(value.Enum()); enumValue != nil {
+			return string(enumValue.Name())
+		}
+		return int64(value.Enum())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return value.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return value.Uint()
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return value.Float()
+	default:
+		return value.Interface()
+	}
+}
+
+type encoder struct {
+	errors base.PathErrors
+}
+
+func (this *encoder) mismatch(path string, format string, args ...interface{}) {
+	this.errors = append(this.errors, base.PathError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (this *encoder) setMessage(path string, msg protoreflect.Message, in interface{}) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		this.mismatch(path, "expected structure, got %#v", in)
+		return
+	}
+	fields := msg.Descriptor().Fields()
+	for name, element := range m {
+		field := fields.ByName(protoreflect.Name(name))
+		if field == nil {
+			this.mismatch(path+"."+name, "unknown field")
+			continue
+		}
+		if element == nil {
+			continue
+		}
+		fieldPath := path + "." + name
+		switch {
+		case field.IsList():
+			elements, ok := element.([]interface{})
+			if !ok {
+				this.mismatch(fieldPath, "expected list, got %#v", element)
+				continue
+			}
+			list := msg.Mutable(field).List()
+			for i, e := range elements {
+				elementPath := fieldPath + "[" + strconv.Itoa(i) + "]"
+				if field.Message() != nil {
+					value := list.NewElement()
+					this.setMessage(elementPath, value.Message(), e)
+					list.Append(value)
+				} else if value, ok := this.scalar(elementPath, field, e); ok {
+					list.Append(value)
+				}
+			}
+		case field.IsMap():
+			elements, ok := element.(map[string]interface{})
+			if !ok {
+				this.mismatch(fieldPath, "expected map, got %#v", element)
+				continue
+			}
+			protoMap := msg.Mutable(field).Map()
+			for key, e := range elements {
+				elementPath := fieldPath + "." + key
+				mapKey, ok := this.scalar(elementPath, field.MapKey(), key)
+				if !ok {
+					continue
+				}
+				if field.MapValue().Message() != nil {
+					value := protoMap.NewValue()
+					this.setMessage(elementPath, value.Message(), e)
+					protoMap.Set(mapKey.MapKey(), value)
+				} else if value, ok := this.scalar(elementPath, field.MapValue(), e); ok {
+					protoMap.Set(mapKey.MapKey(), value)
+				}
+			}
+		case field.Message() != nil:
+			this.setMessage(fieldPath, msg.Mutable(field).Message(), element)
+		default:
+			if value, ok := this.scalar(fieldPath, field, element); ok {
+				msg.Set(field, value)
+			}
+		}
+	}
+}
+
+func (this *encoder) scalar(path string, field protoreflect.FieldDescriptor, in interface{}) (result protoreflect.Value, ok bool) {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := in.(bool); ok {
+			return protoreflect.ValueOfBool(b), true
+		}
+	case protoreflect.StringKind:
+		if str, ok := in.(string); ok {
+			return protoreflect.ValueOfString(str), true
+		}
+	case protoreflect.BytesKind:
+		switch b := in.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(b), true
+		case string:
+			return protoreflect.ValueOfBytes([]byte(b)), true
+		}
+	case protoreflect.EnumKind:
+		if name, ok := in.(string); ok {
+			if enumValue := field.Enum().Values().ByName(protoreflect.Name(name)); enumValue != nil {
+				return protoreflect.ValueOfEnum(enumValue.Number()), true
+			}
+		} else if i, ok := base.ToInt64(in); ok && i >= math.MinInt32 && i <= math.MaxInt32 {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), true
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if i, ok := base.ToInt64(in); ok {
+			if i < math.MinInt32 || i > math.MaxInt32 {
+				return this.outOfRange(path, field, in)
+			}
+			return protoreflect.ValueOfInt32(int32(i)), true
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if i, ok := base.ToInt64(in); ok {
+			return protoreflect.ValueOfInt64(i), true
+		}
+		if _, ok := base.ToUint64(in); ok {
+			return this.outOfRange(path, field, in)
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if u, ok := base.ToUint64(in); ok {
+			if u > math.MaxUint32 {
+				return this.outOfRange(path, field, in)
+			}
+			return protoreflect.ValueOfUint32(uint32(u)), true
+		}
+		if _, ok := base.ToInt64(in); ok {
+			return this.outOfRange(path, field, in)
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if u, ok := base.ToUint64(in); ok {
+			return protoreflect.ValueOfUint64(u), true
+		}
+		if _, ok := base.ToInt64(in); ok {
+			return this.outOfRange(path, field, in)
+		}
+	case protoreflect.FloatKind:
+		if f, ok := base.ToFloat(in); ok {
+			return protoreflect.ValueOfFloat32(float32(f)), true
+		}
+	case protoreflect.DoubleKind:
+		if f, ok := base.ToFloat(in); ok {
+			return protoreflect.ValueOfFloat64(f), true
+		}
+	}
+	//map keys are always strings
+	if str, isString := in.(string); isString && field.Kind() != protoreflect.StringKind {
+		if _, err := strconv.ParseFloat(str, 64); err == nil {
+			return this.scalar(path, field, json.Number(str))
+		}
+		if parsed, err := strconv.ParseBool(str); err == nil && field.Kind() == protoreflect.BoolKind {
+			return this.scalar(path, field, parsed)
+		}
+	}
+	this.mismatch(path, "%#v does not fit %v", in, field.Kind())
+	return result, false
+}
+
+func (this *encoder) outOfRange(path string, field protoreflect.FieldDescriptor, in interface{}) (result protoreflect.Value, ok bool) {
+	this.mismatch(path, "%#v is out of range for %v", in, field.Kind())
+	return result, false
+}
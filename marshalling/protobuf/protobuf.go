@@ -0,0 +1,79 @@
+package protobuf
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+//uses message descriptors registered with base.RegisterDescriptorSet(); selected by the SerializationOptions
+//	protobuf_message=full.message.Name		required
+//	protobuf_descriptor_set=name			optional; all registered sets are searched if missing
+//segments are encoded as described by base.BinaryEncodingOption (base64 by default)
+type Marshaller struct {
+}
+
+const Format = "protobuf"
+
+const (
+	MessageOption       = "protobuf_message"
+	DescriptorSetOption = "protobuf_descriptor_set"
+)
+
+func init() {
+	base.Register(Format, Marshaller{})
+}
+
+func getMessageDescriptor(variable model.ContentVariable) (result protoreflect.MessageDescriptor, err error) {
+	messageName, ok := base.GetOption(variable, MessageOption)
+	if !ok {
+		return nil, errors.New("missing serialization option " + MessageOption + " for " + variable.Name)
+	}
+	setName, _ := base.GetOption(variable, DescriptorSetOption)
+	return base.FindMessageDescriptor(setName, messageName)
+}
+
+//fields of in without matching message field are returned as base.PathErrors
+func (Marshaller) Marshal(in interface{}, variable model.ContentVariable) (out string, err error) {
+	descriptor, err := getMessageDescriptor(variable)
+	if err != nil {
+		return "", err
+	}
+	msg := dynamicpb.NewMessage(descriptor)
+	encoder := encoder{}
+	encoder.setMessage(variable.Name, msg, in)
+	if len(encoder.errors) > 0 {
+		return "", encoder.errors
+	}
+	temp, err := proto.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	return base.EncodeSegment(temp, variable)
+}
+
+//unknown fields in the payload are returned as base.PathErrors; values are coerced like the json format (see base.Coerce())
+func (Marshaller) Unmarshal(in string, variable model.ContentVariable) (out interface{}, err error) {
+	descriptor, err := getMessageDescriptor(variable)
+	if err != nil {
+		return nil, err
+	}
+	temp, err := base.DecodeSegment(in, variable)
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(descriptor)
+	err = proto.Unmarshal(temp, msg)
+	if err != nil {
+		return nil, err
+	}
+	decoder := decoder{}
+	out = decoder.message(variable.Name, msg)
+	if len(decoder.errors) > 0 {
+		return out, decoder.errors
+	}
+	return base.Coerce(out, variable)
+}
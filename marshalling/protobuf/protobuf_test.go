@@ -0,0 +1,206 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func field(name string, number int32, fieldType descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+	result := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Type:   fieldType.Enum(),
+		Label:  label.Enum(),
+	}
+	if typeName != "" {
+		result.TypeName = proto.String(typeName)
+	}
+	return result
+}
+
+const optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+const repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+func registerTestDescriptors(t *testing.T) {
+	err := base.RegisterDescriptorSet("test", &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Mode"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("OFF"), Number: proto.Int32(0)},
+				{Name: proto.String("AUTO"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Reading"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("level", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+					field("temperature", 2, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, optional, ""),
+					field("unit", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+					field("mode", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, optional, ".test.Mode"),
+					field("history", 5, descriptorpb.FieldDescriptorProto_TYPE_SINT64, repeated, ""),
+					field("location", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".test.Location"),
+				},
+			},
+			{
+				Name: proto.String("Location"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("room", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				},
+			},
+			{
+				Name: proto.String("Counters"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("level", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+					field("small", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT32, optional, ""),
+					field("counter", 3, descriptorpb.FieldDescriptorProto_TYPE_UINT64, optional, ""),
+					field("total", 4, descriptorpb.FieldDescriptorProto_TYPE_SINT64, optional, ""),
+				},
+			},
+			{
+				Name: proto.String("ReadingV1"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("level", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+				},
+			},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+var variable = model.ContentVariable{
+	Name:                 "reading",
+	Type:                 model.Structure,
+	SerializationOptions: []string{"protobuf_descriptor_set=test", "protobuf_message=test.Reading"},
+	SubContentVariables: []model.ContentVariable{
+		{Name: "level", Type: model.Integer},
+		{Name: "temperature", Type: model.Float},
+		{Name: "unit", Type: model.String},
+		{Name: "mode", Type: model.String},
+		{Name: "history", Type: model.List, SubContentVariables: []model.ContentVariable{{Name: "*", Type: model.Integer}}},
+		{Name: "location", Type: model.Structure, SubContentVariables: []model.ContentVariable{{Name: "room", Type: model.String}}},
+	},
+}
+
+func TestRoundTrip(t *testing.T) {
+	registerTestDescriptors(t)
+	defer base.UnregisterDescriptorSet("test")
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("protobuf marshaller not registered")
+	}
+	value := map[string]interface{}{
+		"level":       int64(42),
+		"temperature": 21.5,
+		"unit":        "°C",
+		"mode":        "AUTO",
+		"history":     []interface{}{int64(-1), int64(2)},
+		"location":    map[string]interface{}{"room": "kitchen"},
+	}
+	serialized, err := marshaller.Marshal(value, variable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := marshaller.Unmarshal(serialized, variable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, value) {
+		t.Fatal(out)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	registerTestDescriptors(t)
+	defer base.UnregisterDescriptorSet("test")
+	marshaller, _ := base.Get(Format)
+	_, err := marshaller.Marshal(map[string]interface{}{"level": 1, "color": "red"}, variable)
+	if err == nil {
+		t.Fatal("expected unknown field error")
+	}
+
+	serialized, err := marshaller.Marshal(map[string]interface{}{"level": 1, "unit": "%"}, variable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := model.ContentVariable{Name: "reading", SerializationOptions: []string{"protobuf_message=test.ReadingV1"}}
+	out, err := marshaller.Unmarshal(serialized, v1)
+	pathErrors, ok := err.(base.PathErrors)
+	if !ok || len(pathErrors) != 1 || pathErrors[0].Path != "reading" {
+		t.Fatal(out, err)
+	}
+}
+
+var counters = model.ContentVariable{
+	Name:                 "counters",
+	Type:                 model.Structure,
+	SerializationOptions: []string{"protobuf_descriptor_set=test", "protobuf_message=test.Counters"},
+	SubContentVariables: []model.ContentVariable{
+		{Name: "level", Type: model.Integer},
+		{Name: "small", Type: model.Integer},
+		{Name: "counter", Type: model.Integer},
+		{Name: "total", Type: model.Integer},
+	},
+}
+
+func TestLargeIntegers(t *testing.T) {
+	registerTestDescriptors(t)
+	defer base.UnregisterDescriptorSet("test")
+	marshaller, _ := base.Get(Format)
+	//2^53+1 and 2^64-1 can not be represented by float64
+	for _, in := range []interface{}{uint64(9007199254740993), uint64(18446744073709551615), json.Number("18446744073709551615"), "9007199254740993"} {
+		serialized, err := marshaller.Marshal(map[string]interface{}{"counter": in}, counters)
+		if err != nil {
+			t.Fatal(in, err)
+		}
+		out, err := marshaller.Unmarshal(serialized, counters)
+		if err != nil {
+			t.Fatal(in, err)
+		}
+		expected := fmt.Sprint(in)
+		if actual := fmt.Sprint(out.(map[string]interface{})["counter"]); actual != expected {
+			t.Fatal(in, actual)
+		}
+	}
+	serialized, err := marshaller.Marshal(map[string]interface{}{"total": json.Number("-9007199254740993")}, counters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := marshaller.Unmarshal(serialized, counters)
+	if err != nil || out.(map[string]interface{})["total"] != int64(-9007199254740993) {
+		t.Fatal(out, err)
+	}
+}
+
+func TestIntegerOutOfRange(t *testing.T) {
+	registerTestDescriptors(t)
+	defer base.UnregisterDescriptorSet("test")
+	marshaller, _ := base.Get(Format)
+	for _, in := range []map[string]interface{}{
+		{"level": int64(math.MaxInt32) + 1},
+		{"level": json.Number("-2147483649")},
+		{"small": int64(math.MaxUint32) + 1},
+		{"small": -1},
+		{"counter": -1},
+		{"counter": json.Number("-1")},
+		{"counter": json.Number("18446744073709551616")},
+		{"total": uint64(math.MaxInt64) + 1},
+	} {
+		_, err := marshaller.Marshal(in, counters)
+		if _, ok := err.(base.PathErrors); !ok {
+			t.Fatal(in, err)
+		}
+	}
+}
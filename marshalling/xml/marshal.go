@@ -6,13 +6,61 @@ import (
 )
 
 func (Marshaller) Marshal(in interface{}, variable model.ContentVariable) (out string, err error) {
-	mv, ok := in.(map[string]interface{})
-	if !ok {
-		mv = map[string]interface{}{variable.Name: in}
+	namespaces := getNamespaces(variable, nil)
+	value := marshalValue(in, variable)
+	mv, ok := value.(map[string]interface{})
+	if !ok && len(namespaces) == 0 {
+		mv = map[string]interface{}{variable.Name: value}
 		temp, err := mxj.Map(mv).Xml()
 		return string(temp), err
-	} else {
-		temp, err := mxj.Map(mv).Xml(variable.Name)
-		return string(temp), err
+	}
+	if !ok {
+		mv = map[string]interface{}{"#text": value}
+	}
+	for prefix, uri := range namespaces {
+		mv["-xmlns:"+prefix] = uri
+	}
+	temp, err := mxj.Map(mv).Xml(getOptions(variable).marshalKey(variable.Name))
+	return string(temp), err
+}
+
+//translates the value to the mxj map representation described by the variable options; values without sub variable are used unchanged
+func marshalValue(in interface{}, variable model.ContentVariable) interface{} {
+	switch value := in.(type) {
+	case map[string]interface{}:
+		if len(variable.SubContentVariables) == 0 {
+			return value
+		}
+		result := map[string]interface{}{}
+		for key, element := range value {
+			result[key] = element
+		}
+		for _, sub := range variable.SubContentVariables {
+			element, ok := value[sub.Name]
+			if !ok {
+				continue
+			}
+			delete(result, sub.Name)
+			result[getOptions(sub).marshalKey(sub.Name)] = marshalValue(element, sub)
+		}
+		return result
+	case []interface{}:
+		elementVariable, ok := listElementVariable(variable)
+		result := []interface{}{}
+		for _, element := range value {
+			if ok {
+				element = marshalValue(element, elementVariable)
+			}
+			result = append(result, element)
+		}
+		if item := getOptions(variable).listItem; item != "" {
+			if len(result) == 0 {
+				return map[string]interface{}{}
+			}
+			return map[string]interface{}{item: result}
+		}
+		return result
+	default:
+		return in
 	}
 }
@@ -6,7 +6,7 @@ import (
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
 )
 
-func ExampleMarshalPrimitiveInt() {
+func ExampleMarshaller_Marshal_primitiveInt() {
 	value := 24
 	marshaller, ok := base.Get(Format)
 	if !ok {
@@ -21,7 +21,7 @@ func ExampleMarshalPrimitiveInt() {
 	//<int>24</int> <nil>
 }
 
-func ExampleMarshalPrimitiveFloat() {
+func ExampleMarshaller_Marshal_primitiveFloat() {
 	value := 2.4
 	marshaller, ok := base.Get(Format)
 	if !ok {
@@ -36,7 +36,7 @@ func ExampleMarshalPrimitiveFloat() {
 	//<f>2.4</f> <nil>
 }
 
-func ExampleMarshalPrimitiveString() {
+func ExampleMarshaller_Marshal_primitiveString() {
 	value := "foo"
 	marshaller, ok := base.Get(Format)
 	if !ok {
@@ -51,7 +51,7 @@ func ExampleMarshalPrimitiveString() {
 	//<str>foo</str> <nil>
 }
 
-func ExampleMarshalPrimitiveBool() {
+func ExampleMarshaller_Marshal_primitiveBool() {
 	value := true
 	marshaller, ok := base.Get(Format)
 	if !ok {
@@ -66,7 +66,7 @@ func ExampleMarshalPrimitiveBool() {
 	//<b>true</b> <nil>
 }
 
-func ExampleMarshal() {
+func ExampleMarshaller_Marshal() {
 	value := map[string]interface{}{"-attr": "attrVal", "body": "bodyVal"}
 	marshaller, ok := base.Get(Format)
 	if !ok {
@@ -74,8 +74,8 @@ func ExampleMarshal() {
 	}
 
 	fmt.Println(marshaller.Marshal(value, model.ContentVariable{
-		Name:      "example",
-		ValueType: model.Structure,
+		Name: "example",
+		Type: model.Structure,
 		SubContentVariables: []model.ContentVariable{
 			{Name: "-attr"},
 			{Name: "body"},
@@ -85,5 +85,3 @@ func ExampleMarshal() {
 	// Output:
 	//<example attr="attrVal"><body>bodyVal</body></example> <nil>
 }
-
-//TODO: lists
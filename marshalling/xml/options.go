@@ -0,0 +1,105 @@
+package xml
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"strings"
+)
+
+//SerializationOptions of ContentVariables:
+//	xml_attribute				the variable is an attribute of the parent element
+//	xml_text					the variable is the text content of the parent element
+//	xml_namespace=prefix:uri	the element or attribute is prefixed; the namespace is declared on the root element
+//	xml_list_item=name			list elements are serialized as repeated <name> children of the list element
+//									(without this option list elements repeat the list element itself)
+const (
+	AttributeOption = "xml_attribute"
+	TextOption      = "xml_text"
+	NamespaceOption = "xml_namespace"
+	ListItemOption  = "xml_list_item"
+)
+
+type options struct {
+	attribute bool
+	text      bool
+	prefix    string
+	uri       string
+	listItem  string
+}
+
+func getOptions(variable model.ContentVariable) (result options) {
+	for _, option := range variable.SerializationOptions {
+		parts := strings.SplitN(option, "=", 2)
+		switch parts[0] {
+		case AttributeOption:
+			result.attribute = true
+		case TextOption:
+			result.text = true
+		case NamespaceOption:
+			if len(parts) == 2 {
+				namespace := strings.SplitN(parts[1], ":", 2)
+				if len(namespace) == 2 {
+					result.prefix = namespace[0]
+					result.uri = namespace[1]
+				}
+			}
+		case ListItemOption:
+			if len(parts) == 2 {
+				result.listItem = parts[1]
+			}
+		}
+	}
+	return
+}
+
+//key of the variable in a mxj map used for Marshal
+func (this options) marshalKey(name string) string {
+	if this.text {
+		return "#text"
+	}
+	if this.prefix != "" {
+		name = this.prefix + ":" + name
+	}
+	if this.attribute {
+		return "-" + name
+	}
+	return name
+}
+
+//key of the variable in a mxj map created by Unmarshal (mxj removes namespace prefixes)
+func (this options) unmarshalKey(name string) string {
+	if this.text {
+		return "#text"
+	}
+	if this.attribute {
+		return "-" + name
+	}
+	return name
+}
+
+//prefix -> uri of all namespaces used in the variable tree
+func getNamespaces(variable model.ContentVariable, result map[string]string) map[string]string {
+	if result == nil {
+		result = map[string]string{}
+	}
+	opt := getOptions(variable)
+	if opt.prefix != "" {
+		result[opt.prefix] = opt.uri
+	}
+	for _, sub := range variable.SubContentVariables {
+		getNamespaces(sub, result)
+	}
+	return result
+}
+
+//returns the variable describing list elements (named "*" or the first sub variable)
+func listElementVariable(variable model.ContentVariable) (result model.ContentVariable, ok bool) {
+	for _, sub := range variable.SubContentVariables {
+		if sub.Name == "*" {
+			return sub, true
+		}
+	}
+	if len(variable.SubContentVariables) > 0 {
+		return variable.SubContentVariables[0], true
+	}
+	return result, false
+}
@@ -0,0 +1,138 @@
+package xml
+
+import (
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"reflect"
+	"testing"
+)
+
+var roundTripVariable = model.ContentVariable{
+	Name:                 "reading",
+	Type:                 model.Structure,
+	SerializationOptions: []string{"xml_namespace=ba:http://example.com/building"},
+	SubContentVariables: []model.ContentVariable{
+		{Name: "id", Type: model.String, SerializationOptions: []string{"xml_attribute"}},
+		{Name: "value", Type: model.Structure, SerializationOptions: []string{"xml_namespace=ba:http://example.com/building"}, SubContentVariables: []model.ContentVariable{
+			{Name: "unit", Type: model.String, SerializationOptions: []string{"xml_attribute"}},
+			{Name: "level", Type: model.Float, SerializationOptions: []string{"xml_text"}},
+		}},
+		{Name: "history", Type: model.List, SerializationOptions: []string{"xml_list_item=entry"}, SubContentVariables: []model.ContentVariable{
+			{Name: "*", Type: model.Float},
+		}},
+	},
+}
+
+func TestMarshalOptions(t *testing.T) {
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("xml marshaller not registered")
+	}
+	out, err := marshaller.Marshal(map[string]interface{}{
+		"id":      "sensor1",
+		"value":   map[string]interface{}{"unit": "°C", "level": 21.5},
+		"history": []interface{}{20.5, 21},
+	}, roundTripVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<ba:reading id="sensor1" xmlns:ba="http://example.com/building"><ba:value unit="°C">21.5</ba:value><history><entry>20.5</entry><entry>21</entry></history></ba:reading>`
+	if out != expected {
+		t.Fatal(out)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("xml marshaller not registered")
+	}
+	values := []interface{}{
+		map[string]interface{}{
+			"id":      "sensor1",
+			"value":   map[string]interface{}{"unit": "°C", "level": 21.5},
+			"history": []interface{}{20.5, float64(21)},
+		},
+		map[string]interface{}{
+			"id":      "sensor2",
+			"value":   map[string]interface{}{"unit": "%", "level": float64(40)},
+			"history": []interface{}{float64(3)},
+		},
+		map[string]interface{}{
+			"id":      "sensor3",
+			"value":   map[string]interface{}{"unit": "%", "level": float64(40)},
+			"history": []interface{}{},
+		},
+	}
+	for _, value := range values {
+		serialized, err := marshaller.Marshal(value, roundTripVariable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := marshaller.Unmarshal(serialized, roundTripVariable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, value) {
+			t.Fatal(serialized, out)
+		}
+	}
+}
+
+func TestUnmarshalListItems(t *testing.T) {
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("xml marshaller not registered")
+	}
+	out, err := marshaller.Unmarshal(`<list><element>1</element><element>2</element><element>3</element></list>`, model.ContentVariable{
+		Name:                 "list",
+		Type:                 model.List,
+		SerializationOptions: []string{"xml_list_item=element"},
+		SubContentVariables: []model.ContentVariable{
+			{Name: "*", Type: model.Integer},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, []interface{}{float64(1), float64(2), float64(3)}) {
+		t.Fatal(out)
+	}
+}
+
+func TestUnmarshalAttributeNamedLikePrefix(t *testing.T) {
+	marshaller, ok := base.Get(Format)
+	if !ok {
+		t.Fatal("xml marshaller not registered")
+	}
+	out, err := marshaller.Unmarshal(`<ba:reading id="sensor1" xmlns:ba="http://example.com/building"><ba:value ba="east" unit="°C">21.5</ba:value></ba:reading>`, roundTripVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"id":    "sensor1",
+		"value": map[string]interface{}{"-ba": "east", "unit": "°C", "level": 21.5},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatal(out)
+	}
+}
+
+func TestRoundTripNamespacedScalar(t *testing.T) {
+	marshaller, _ := base.Get(Format)
+	variable := model.ContentVariable{Name: "n", Type: model.Integer, SerializationOptions: []string{"xml_namespace=a:urn:a"}}
+	serialized, err := marshaller.Marshal(5, variable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serialized != `<a:n xmlns:a="urn:a">5</a:n>` {
+		t.Fatal(serialized)
+	}
+	out, err := marshaller.Unmarshal(serialized, variable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, float64(5)) {
+		t.Fatalf("%#v", out)
+	}
+}
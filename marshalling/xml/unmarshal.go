@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
 	"github.com/clbanning/mxj"
+	"strings"
 )
 
 func (Marshaller) Unmarshal(in string, variable model.ContentVariable) (out interface{}, err error) {
@@ -15,5 +16,78 @@ func (Marshaller) Unmarshal(in string, variable model.ContentVariable) (out inte
 	if !ok {
 		return out, errors.New("root element tag != root variable name")
 	}
-	return out, nil
+	return unmarshalValue(out, variable, getNamespaces(variable, nil)), nil
+}
+
+//translates the mxj map representation to the value described by the variable options; values without sub variable are used unchanged
+func unmarshalValue(in interface{}, variable model.ContentVariable, namespaces map[string]string) interface{} {
+	if item := getOptions(variable).listItem; item != "" {
+		return unmarshalList(in, variable, item, namespaces)
+	}
+	value, ok := in.(map[string]interface{})
+	if !ok {
+		return in
+	}
+	result := withoutNamespaceDeclarations(value, namespaces)
+	if len(variable.SubContentVariables) == 0 {
+		//namespaced scalars are marshalled as {"#text": value, "-xmlns:prefix": uri}
+		if text, ok := result["#text"]; ok && len(result) == 1 {
+			return text
+		}
+		if len(result) == len(value) {
+			return in
+		}
+		return result
+	}
+	for _, sub := range variable.SubContentVariables {
+		key := getOptions(sub).unmarshalKey(sub.Name)
+		element, ok := value[key]
+		if !ok {
+			continue
+		}
+		delete(result, key)
+		result[sub.Name] = unmarshalValue(element, sub, namespaces)
+	}
+	return result
+}
+
+//returns a copy of value without namespace declarations.
+//mxj strips the xmlns: of namespace declarations; attributes named like a prefix but with an other value are kept
+func withoutNamespaceDeclarations(value map[string]interface{}, namespaces map[string]string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, element := range value {
+		result[key] = element
+	}
+	for prefix, uri := range namespaces {
+		if declaration, ok := result["-"+prefix]; ok && declaration == uri {
+			delete(result, "-"+prefix)
+		}
+	}
+	return result
+}
+
+func unmarshalList(in interface{}, variable model.ContentVariable, item string, namespaces map[string]string) interface{} {
+	if index := strings.Index(item, ":"); index >= 0 {
+		item = item[index+1:]
+	}
+	result := []interface{}{}
+	value, ok := in.(map[string]interface{})
+	if !ok {
+		return result //empty list element
+	}
+	elements, ok := value[item].([]interface{})
+	if !ok {
+		elements = []interface{}{value[item]}
+		if value[item] == nil {
+			elements = []interface{}{}
+		}
+	}
+	elementVariable, ok := listElementVariable(variable)
+	for _, element := range elements {
+		if ok {
+			element = unmarshalValue(element, elementVariable, namespaces)
+		}
+		result = append(result, element)
+	}
+	return result
 }
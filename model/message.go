@@ -16,7 +16,13 @@
 
 package model
 
-import "strings"
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type TaskInfo struct {
 	WorkerId            string `json:"worker_id"`
@@ -27,8 +33,24 @@ type TaskInfo struct {
 	Time                string `json:"time"`
 }
 
+//parses TaskInfo.Time as unix timestamp in seconds or milliseconds or as RFC3339 string
+func (this TaskInfo) ParseTime() (result time.Time, err error) {
+	if this.Time == "" {
+		return result, errors.New("missing task time")
+	}
+	unix, err := strconv.ParseInt(this.Time, 10, 64)
+	if err == nil {
+		if unix > 1e12 {
+			return time.Unix(0, unix*int64(time.Millisecond)), nil
+		}
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, this.Time)
+}
+
 type ProtocolRequest struct {
-	Input map[string]string `json:"input"`
+	Input  map[string]string      `json:"input"`
+	Values map[string]interface{} `json:"values,omitempty"` //structured input by ContentVariable.Name of Metadata.Service.Inputs; serialized into Input before the command handler is called
 }
 
 type ProtocolResponse struct {
@@ -56,6 +78,22 @@ type Envelope struct {
 	DeviceId  string      `json:"device_id,omitempty"`
 	ServiceId string      `json:"service_id,omitempty"`
 	Value     interface{} `json:"value"`
+	Time      time.Time   `json:"time"` //measurement time of the event; also used as kafka record timestamp. omitted by MarshalJSON() if zero
+
+	Annotations     []string          `json:"annotations,omitempty"`     //validation violations of Value (see Config.EventValidation)
+	Characteristics map[string]string `json:"characteristics,omitempty"` //characteristic ids of the parts of Value by their path (e.g. temperature.value)
+}
+
+//omits the zero Time, which is not omitted by the omitempty tag of a struct
+func (this Envelope) MarshalJSON() ([]byte, error) {
+	type envelope Envelope //without MarshalJSON()
+	if !this.Time.IsZero() {
+		return json.Marshal(envelope(this))
+	}
+	return json.Marshal(struct {
+		envelope
+		Time *time.Time `json:"time,omitempty"`
+	}{envelope: envelope(this)})
 }
 
 func ServiceIdToTopic(id string) string {
@@ -25,6 +25,20 @@ import (
 )
 
 func New(authEndpoint string, authClientId string, authClientSecret string, jwtIssuer string, jwtPrivateKey string, jwtExpiration int64, authExpirationTimeBuffer float64, tokenCacheExpiration int32, cacheUrls []string) *Security {
+	var backend cache.Backend
+	if len(cacheUrls) > 0 {
+		backend = cache.NewMemcachedBackend(cacheUrls...)
+	}
+	return NewWithCacheBackend(authEndpoint, authClientId, authClientSecret, jwtIssuer, jwtPrivateKey, jwtExpiration, authExpirationTimeBuffer, tokenCacheExpiration, backend)
+}
+
+//tokens are not cached if tokenCacheExpiration is 0 or cacheBackend is nil
+func NewWithCacheBackend(authEndpoint string, authClientId string, authClientSecret string, jwtIssuer string, jwtPrivateKey string, jwtExpiration int64, authExpirationTimeBuffer float64, tokenCacheExpiration int32, cacheBackend cache.Backend) *Security {
+	return NewWithCacheOptions(authEndpoint, authClientId, authClientSecret, jwtIssuer, jwtPrivateKey, jwtExpiration, authExpirationTimeBuffer, tokenCacheExpiration, cacheBackend, cache.Options{})
+}
+
+//tokens are not cached if tokenCacheExpiration is 0 or if cacheBackend is nil and cacheOptions.DisableL2 is not set
+func NewWithCacheOptions(authEndpoint string, authClientId string, authClientSecret string, jwtIssuer string, jwtPrivateKey string, jwtExpiration int64, authExpirationTimeBuffer float64, tokenCacheExpiration int32, cacheBackend cache.Backend, cacheOptions cache.Options) *Security {
 	result := &Security{
 		authEndpoint:             authEndpoint,
 		authClientSecret:         authClientSecret,
@@ -35,8 +49,8 @@ func New(authEndpoint string, authClientId string, authClientSecret string, jwtI
 		jwtExpiration:            jwtExpiration,
 		tokenCacheExpiration:     tokenCacheExpiration,
 	}
-	if tokenCacheExpiration != 0 && len(cacheUrls) > 0 {
-		result.cache = cache.New(cacheUrls...)
+	if tokenCacheExpiration != 0 && (cacheBackend != nil || cacheOptions.DisableL2) {
+		result.cache = cache.NewWithOptions(cacheBackend, cacheOptions)
 	}
 	return result
 }
@@ -0,0 +1,322 @@
+/*
+ * Copyright 2019 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/platform-connector-lib/marshalling/base"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"golang.org/x/sync/singleflight"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mode string
+
+const (
+	Disabled Mode = ""
+	Reject   Mode = "reject" //values with violations are returned as error
+	Clamp    Mode = "clamp"  //numbers outside of the characteristic bounds are clamped; other violations are returned as error
+	Flag     Mode = "flag"   //values are used unchanged; violations are returned for annotation
+)
+
+//maximal number of device/service pairs with individual violation counts; violations of further pairs are counted with empty DeviceId and ServiceId
+const MaxCounts = 10000
+
+//characteristics which could not be loaded are requested again after this interval
+const LoaderRetryInterval = time.Minute
+
+//loads the characteristic with the given id; used for characteristics unknown to the Validator
+type Loader func(characteristicId string) (model.Characteristic, error)
+
+//checks values against the types of their ContentVariables and the MinValue/MaxValue of their characteristics; safe for concurrent use
+type Validator struct {
+	mode            Mode
+	mux             sync.RWMutex
+	characteristics map[string]model.Characteristic
+	counts          map[countKey]uint64
+	loader          Loader
+	loads           singleflight.Group
+	failed          map[string]time.Time
+}
+
+type countKey struct {
+	deviceId  string
+	serviceId string
+}
+
+type Count struct {
+	DeviceId   string `json:"device_id"`
+	ServiceId  string `json:"service_id"`
+	Violations uint64 `json:"violations"` //number of values with violations
+}
+
+//returns the Mode matching value (case insensitive)
+func ParseMode(value string) (mode Mode, err error) {
+	mode = Mode(strings.ToLower(strings.TrimSpace(value)))
+	switch mode {
+	case Disabled, Reject, Clamp, Flag:
+		return mode, nil
+	default:
+		return mode, errors.New("unknown validation mode " + value)
+	}
+}
+
+//unknown modes are logged and handled as Reject
+func New(mode Mode) *Validator {
+	parsed, err := ParseMode(string(mode))
+	if err != nil {
+		log.Println("ERROR: validation.New()", err, "--> use", Reject)
+		parsed = Reject
+	}
+	return &Validator{mode: parsed, characteristics: map[string]model.Characteristic{}, counts: map[countKey]uint64{}, failed: map[string]time.Time{}}
+}
+
+func (this *Validator) Mode() Mode {
+	return this.mode
+}
+
+//characteristics (and their sub characteristics) whose MinValue and MaxValue are used as bounds; bounds with MinValue == MaxValue are ignored
+func (this *Validator) SetCharacteristics(characteristics ...model.Characteristic) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.setCharacteristics(characteristics)
+}
+
+//loader is called for characteristic ids of validated variables which are not set with SetCharacteristics()
+func (this *Validator) SetLoader(loader Loader) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.loader = loader
+}
+
+func (this *Validator) setCharacteristics(characteristics []model.Characteristic) {
+	for _, characteristic := range characteristics {
+		this.characteristics[characteristic.Id] = characteristic
+		this.setCharacteristics(characteristic.SubCharacteristics)
+	}
+}
+
+//returns the (clamped) value and all violations; err is set if the value must not be used
+func (this *Validator) Validate(deviceId string, serviceId string, value interface{}, variable model.ContentVariable) (result interface{}, violations base.PathErrors, err error) {
+	if this.mode == Disabled {
+		return value, nil, nil
+	}
+	this.load(variable)
+	walker := walker{validator: this, clamp: this.mode == Clamp}
+	this.mux.RLock()
+	result = walker.walk(variable.Name, value, variable)
+	this.mux.RUnlock()
+	if len(walker.violations) == 0 {
+		return result, nil, nil
+	}
+	this.count(countKey{deviceId: deviceId, serviceId: serviceId})
+	switch this.mode {
+	case Reject:
+		return value, walker.violations, walker.violations
+	case Clamp:
+		if walker.unclampable {
+			return value, walker.violations, walker.violations
+		}
+		return result, walker.violations, nil
+	default:
+		return value, walker.violations, nil
+	}
+}
+
+func (this *Validator) count(key countKey) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, ok := this.counts[key]; !ok && len(this.counts) >= MaxCounts {
+		key = countKey{}
+	}
+	this.counts[key]++
+}
+
+//loads the unknown characteristics of variable with the Loader
+func (this *Validator) load(variable model.ContentVariable) {
+	this.mux.RLock()
+	loader := this.loader
+	var missing []string
+	if loader != nil {
+		missing = this.missing(variable, nil, time.Now())
+	}
+	this.mux.RUnlock()
+	for _, id := range missing {
+		id := id
+		this.loads.Do(id, func() (interface{}, error) {
+			characteristic, err := loader(id)
+			this.mux.Lock()
+			defer this.mux.Unlock()
+			if err != nil {
+				log.Println("WARNING: unable to load characteristic bounds", id, err)
+				this.failed[id] = time.Now()
+				return nil, err
+			}
+			delete(this.failed, id)
+			characteristic.Id = id
+			this.setCharacteristics([]model.Characteristic{characteristic})
+			return nil, nil
+		})
+	}
+}
+
+//characteristic ids of variable and its sub variables which are unknown and did not fail to load in the last LoaderRetryInterval
+func (this *Validator) missing(variable model.ContentVariable, result []string, now time.Time) []string {
+	if variable.CharacteristicId != "" {
+		_, known := this.characteristics[variable.CharacteristicId]
+		failed, ok := this.failed[variable.CharacteristicId]
+		if !known && (!ok || now.Sub(failed) > LoaderRetryInterval) {
+			result = append(result, variable.CharacteristicId)
+		}
+	}
+	for _, sub := range variable.SubContentVariables {
+		result = this.missing(sub, result, now)
+	}
+	return result
+}
+
+//violation counts by device and service, sorted by DeviceId and ServiceId
+func (this *Validator) Counts() (result []Count) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	for key, count := range this.counts {
+		result = append(result, Count{DeviceId: key.deviceId, ServiceId: key.serviceId, Violations: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DeviceId != result[j].DeviceId {
+			return result[i].DeviceId < result[j].DeviceId
+		}
+		return result[i].ServiceId < result[j].ServiceId
+	})
+	return result
+}
+
+type walker struct {
+	validator   *Validator
+	clamp       bool
+	violations  base.PathErrors
+	unclampable bool
+}
+
+func (this *walker) violation(path string, clampable bool, format string, args ...interface{}) {
+	this.violations = append(this.violations, base.PathError{Path: path, Message: fmt.Sprintf(format, args...)})
+	if !clampable {
+		this.unclampable = true
+	}
+}
+
+func (this *walker) walk(path string, value interface{}, variable model.ContentVariable) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch variable.Type {
+	case model.String:
+		if _, ok := value.(string); !ok {
+			this.violation(path, false, "expected string, got %#v", value)
+		}
+		return value
+	case model.Boolean:
+		if _, ok := value.(bool); !ok {
+			this.violation(path, false, "expected boolean, got %#v", value)
+		}
+		return value
+	case model.Integer, model.Float:
+		f, ok := base.ToFloat(value)
+		if !ok {
+			this.violation(path, false, "expected number, got %#v", value)
+			return value
+		}
+		if variable.Type == model.Integer && f != math.Trunc(f) {
+			this.violation(path, false, "expected integer, got %v", f)
+			return value
+		}
+		return this.checkBounds(path, value, f, variable)
+	case model.Structure:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			this.violation(path, false, "expected structure, got %#v", value)
+			return value
+		}
+		result := map[string]interface{}{}
+		for key, element := range m {
+			result[key] = element
+		}
+		for key, element := range m {
+			if sub, ok := subVariable(variable, key); ok {
+				result[key] = this.walk(path+"."+key, element, sub)
+			}
+		}
+		return result
+	case model.List:
+		list, ok := value.([]interface{})
+		if !ok {
+			this.violation(path, false, "expected list, got %#v", value)
+			return value
+		}
+		if len(variable.SubContentVariables) == 0 {
+			return value
+		}
+		result := []interface{}{}
+		for i, element := range list {
+			if sub, ok := subVariable(variable, strconv.Itoa(i)); ok {
+				element = this.walk(fmt.Sprintf("%v[%v]", path, i), element, sub)
+			}
+			result = append(result, element)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+//returns the sub variable named like the structure field or list index or the base.Wildcard sub variable
+func subVariable(variable model.ContentVariable, name string) (result model.ContentVariable, ok bool) {
+	for _, sub := range variable.SubContentVariables {
+		if sub.Name == name {
+			return sub, true
+		}
+	}
+	for _, sub := range variable.SubContentVariables {
+		if sub.Name == base.Wildcard {
+			return sub, true
+		}
+	}
+	return result, false
+}
+
+func (this *walker) checkBounds(path string, value interface{}, f float64, variable model.ContentVariable) interface{} {
+	characteristic, ok := this.validator.characteristics[variable.CharacteristicId]
+	if !ok || characteristic.MinValue == characteristic.MaxValue {
+		return value
+	}
+	bounded := math.Max(characteristic.MinValue, math.Min(characteristic.MaxValue, f))
+	if bounded == f {
+		return value
+	}
+	this.violation(path, true, "%v is outside of [%v, %v]", f, characteristic.MinValue, characteristic.MaxValue)
+	if variable.Type == model.Integer {
+		return int64(bounded)
+	}
+	return bounded
+}
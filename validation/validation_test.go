@@ -0,0 +1,164 @@
+package validation
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+var variable = model.ContentVariable{
+	Name: "reading",
+	Type: model.Structure,
+	SubContentVariables: []model.ContentVariable{
+		{Name: "temperature", Type: model.Float, CharacteristicId: "celsius"},
+		{Name: "level", Type: model.Integer, CharacteristicId: "percent"},
+		{Name: "unit", Type: model.String},
+	},
+}
+
+func newValidator(mode Mode) *Validator {
+	validator := New(mode)
+	validator.SetCharacteristics(
+		model.Characteristic{Id: "celsius", MinValue: -40, MaxValue: 125},
+		model.Characteristic{Id: "percent", MinValue: 0, MaxValue: 100},
+	)
+	return validator
+}
+
+func TestModes(t *testing.T) {
+	value := map[string]interface{}{"temperature": 9999.0, "level": int64(50), "unit": "°C"}
+
+	_, violations, err := newValidator(Reject).Validate("d", "s", value, variable)
+	if err == nil || len(violations) != 1 || violations[0].Path != "reading.temperature" {
+		t.Fatal(violations, err)
+	}
+
+	result, violations, err := newValidator(Clamp).Validate("d", "s", value, variable)
+	if err != nil || len(violations) != 1 {
+		t.Fatal(violations, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"temperature": 125.0, "level": int64(50), "unit": "°C"}) {
+		t.Fatal(result)
+	}
+
+	result, violations, err = newValidator(Flag).Validate("d", "s", value, variable)
+	if err != nil || len(violations) != 1 || !reflect.DeepEqual(result, value) {
+		t.Fatal(result, violations, err)
+	}
+
+	result, violations, err = newValidator(Disabled).Validate("d", "s", value, variable)
+	if err != nil || len(violations) != 0 || !reflect.DeepEqual(result, value) {
+		t.Fatal(result, violations, err)
+	}
+}
+
+func TestClampTypeMismatch(t *testing.T) {
+	_, violations, err := newValidator(Clamp).Validate("d", "s", map[string]interface{}{"temperature": "hot", "level": 4.5}, variable)
+	if err == nil || len(violations) != 2 {
+		t.Fatal(violations, err)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	validator := newValidator(Flag)
+	validator.Validate("d1", "s", map[string]interface{}{"level": int64(101)}, variable)
+	validator.Validate("d1", "s", map[string]interface{}{"level": int64(-1), "temperature": -50.0}, variable)
+	validator.Validate("d2", "s", map[string]interface{}{"level": int64(5)}, variable)
+	validator.Validate("d0", "s", map[string]interface{}{"unit": 1}, variable)
+	counts := validator.Counts()
+	expected := []Count{{DeviceId: "d0", ServiceId: "s", Violations: 1}, {DeviceId: "d1", ServiceId: "s", Violations: 2}}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatal(counts)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("Reject")
+	if err != nil || mode != Reject {
+		t.Fatal(mode, err)
+	}
+	if _, err = ParseMode("drop"); err == nil {
+		t.Fatal("expected unknown mode error")
+	}
+	if mode := New("drop").Mode(); mode != Reject {
+		t.Fatal(mode)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	calls := map[string]int{}
+	validator := New(Reject)
+	validator.SetLoader(func(id string) (model.Characteristic, error) {
+		calls[id]++
+		if id == "percent" {
+			return model.Characteristic{}, errors.New("test error")
+		}
+		return model.Characteristic{Id: id, MinValue: -40, MaxValue: 125}, nil
+	})
+	for i := 0; i < 3; i++ {
+		_, violations, err := validator.Validate("d", "s", map[string]interface{}{"temperature": 9999.0, "level": int64(200)}, variable)
+		if err == nil || len(violations) != 1 || violations[0].Path != "reading.temperature" {
+			t.Fatal(violations, err)
+		}
+	}
+	if !reflect.DeepEqual(calls, map[string]int{"celsius": 1, "percent": 1}) {
+		t.Fatal(calls)
+	}
+}
+
+func TestCountsLimit(t *testing.T) {
+	validator := newValidator(Flag)
+	for i := 0; i < MaxCounts+10; i++ {
+		validator.Validate(strconv.Itoa(i), "s", map[string]interface{}{"level": int64(101)}, variable)
+	}
+	counts := validator.Counts()
+	if len(counts) != MaxCounts+1 || counts[0] != (Count{Violations: 10}) {
+		t.Fatal(len(counts), counts[0])
+	}
+}
+
+func TestIndexedList(t *testing.T) {
+	list := model.ContentVariable{
+		Name: "pair",
+		Type: model.List,
+		SubContentVariables: []model.ContentVariable{
+			{Name: "0", Type: model.Float, CharacteristicId: "celsius"},
+			{Name: "1", Type: model.Integer, CharacteristicId: "percent"},
+		},
+	}
+	result, violations, err := newValidator(Clamp).Validate("d", "s", []interface{}{200.0, int64(150), "extra"}, list)
+	if err != nil || len(violations) != 2 {
+		t.Fatal(violations, err)
+	}
+	if !reflect.DeepEqual(result, []interface{}{125.0, int64(100), "extra"}) {
+		t.Fatal(result)
+	}
+	_, violations, _ = newValidator(Flag).Validate("d", "s", []interface{}{20.0, 4.5}, list)
+	if len(violations) != 1 || violations[0].Path != "pair[1]" {
+		t.Fatal(violations)
+	}
+}
+
+func TestWildcardStructure(t *testing.T) {
+	structure := model.ContentVariable{
+		Name: "levels",
+		Type: model.Structure,
+		SubContentVariables: []model.ContentVariable{
+			{Name: "unit", Type: model.String},
+			{Name: "*", Type: model.Integer, CharacteristicId: "percent"},
+		},
+	}
+	result, violations, err := newValidator(Clamp).Validate("d", "s", map[string]interface{}{"unit": "%", "a": int64(120), "b": int64(50)}, structure)
+	if err != nil || len(violations) != 1 || violations[0].Path != "levels.a" {
+		t.Fatal(violations, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"unit": "%", "a": int64(100), "b": int64(50)}) {
+		t.Fatal(result)
+	}
+	_, violations, _ = newValidator(Flag).Validate("d", "s", map[string]interface{}{"unit": 1, "c": "x"}, structure)
+	if len(violations) != 2 {
+		t.Fatal(violations)
+	}
+}